@@ -0,0 +1,64 @@
+// asyncapi generates an AsyncAPI document describing the topology of the service's config: its
+// exchanges, the routing key of every event type, the queues which its events may be routed to & the
+// headers & payloads of its events, as published with their configured encodings. It reads the
+// same environment as the service. Run it from the repo root with:
+//
+//	go run ./cmd/asyncapi -server amqp://rabbitmq:5672 > asyncapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"gitlab.com/project-leaf/mq-service-go/src/asyncapi"
+	"gitlab.com/project-leaf/mq-service-go/src/broker"
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+func main() {
+	server := flag.String("server", "", "the URL of the broker to list as the document's server, if any")
+	version := flag.String("version", "1.0.0", "the version of the described API")
+	flag.Parse()
+
+	cfg := config.New()
+
+	var servers map[string]asyncapi.Server
+	if *server != "" {
+		servers = map[string]asyncapi.Server{"broker": {URL: *server, Protocol: "amqp"}}
+	}
+
+	info := asyncapi.Info{
+		Title:       "mq-service events",
+		Version:     *version,
+		Description: "The events published by mq-service to the central event bus.",
+	}
+	set, err := mq.FileDescriptorSet()
+	if err != nil {
+		fail("Error loading the event descriptors: %s", err.Error())
+	}
+	topology, err := broker.Describe(cfg)
+	if err != nil {
+		fail("Error describing the topology: %s", err.Error())
+	}
+	doc, err := asyncapi.Generate(info, servers, topology, set)
+	if err != nil {
+		fail("Error generating the AsyncAPI document: %s", err.Error())
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fail("Error encoding the AsyncAPI document: %s", err.Error())
+	}
+	if _, err := os.Stdout.Write(append(data, '\n')); err != nil {
+		fail("Error writing the AsyncAPI document: %s", err.Error())
+	}
+}
+
+// fail will report the given error & exit.
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "asyncapi: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,43 @@
+// protoc-gen-mqservice is a protoc plugin which generates the routing key methods, the
+// `InternalMQService` handlers & the default queue declarations for the events declared by
+// `mq-service.proto`. Run it from the repo root with:
+//
+//	protoc -I leaf-proto/ leaf-proto/mq-service.proto --mqservice_out=src
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"gitlab.com/project-leaf/mq-service-go/src/mqgen"
+)
+
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail("Error reading the code generator request: %s", err.Error())
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		fail("Error parsing the code generator request: %s", err.Error())
+	}
+
+	data, err = proto.Marshal(mqgen.Generate(req))
+	if err != nil {
+		fail("Error encoding the code generator response: %s", err.Error())
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		fail("Error writing the code generator response: %s", err.Error())
+	}
+}
+
+// fail will report the given error to protoc & exit.
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "protoc-gen-mqservice: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/project-leaf/mq-service-go/src/admin"
 	"gitlab.com/project-leaf/mq-service-go/src/api"
 	"gitlab.com/project-leaf/mq-service-go/src/broker"
 	"gitlab.com/project-leaf/mq-service-go/src/config"
@@ -10,17 +17,87 @@ import (
 )
 
 func main() {
+	verifyOnly := flag.Bool("verify-topology", false, "verify the broker topology instead of declaring it")
+	migrate := flag.Bool("migrate-topology", false, "migrate queues declared with other arguments than expected, then exit")
+	dryRun := flag.Bool("dry-run", false, "with -migrate-topology, only print the migration plan")
+	flag.Parse()
+
 	cfg := config.New()
 	log := logging.GetLogger(cfg)
 	broker := broker.New(cfg, log)
 
-	// Ensure MQ topology is ready to rock. We will not crash the server here.
-	if err := broker.EnsureTopology(); err != nil {
-		log.Errorf("Error while ensuring broker topology: %s", err.Error())
+	// Migrate the MQ topology, if asked to, instead of serving.
+	if *migrate {
+		os.Exit(migrateTopology(broker, log, *dryRun))
+	}
+
+	// Ensure MQ topology is ready to rock, unless it is only to be verified. We will not crash the
+	// server here. Any drift from the expected topology is reported by the readiness check.
+	if !*verifyOnly {
+		if err := broker.EnsureTopology(context.Background()); err != nil {
+			log.Errorf("Error while ensuring broker topology: %s", err.Error())
+		}
+	}
+	if _, err := broker.VerifyTopology(context.Background()); err != nil {
+		log.Errorf("Error while verifying broker topology: %s", err.Error())
+	}
+
+	// Keep the MQ topology in place, or keep verifying it if it is only to be verified.
+	if *verifyOnly {
+		go broker.RunVerifier(context.Background())
+	} else {
+		go broker.RunReconciler(context.Background())
 	}
 
+	// Boot the admin listener in the background.
+	adminListener := admin.New(cfg, log)
+	adminListener.AddHealthCheck("broker", broker.HealthCheck)
+	adminListener.AddReadinessCheck("topology", broker.TopologyReadiness)
+	go func() {
+		if err := adminListener.Listen(); err != nil {
+			log.Errorf("Error from admin listener: %T: %s", err, err.Error())
+		}
+	}()
+
 	// Boot the API.
 	if err := api.New(cfg, log, broker).Listen(); err != nil {
 		fmt.Printf("Error from listener: %T: %s", err, err.Error())
 	}
 }
+
+// migrateTopology will plan the broker topology migrations & print the plan, applying it unless
+// this is a dry run. It returns the exit code of the process.
+func migrateTopology(mqBroker *broker.Broker, log *logrus.Logger, dryRun bool) int {
+	plan, err := mqBroker.PlanMigrations(context.Background())
+	if err != nil {
+		log.Errorf("Error while planning broker topology migrations: %s", err.Error())
+		return 1
+	}
+	if err := printJSON(plan); err != nil {
+		log.Errorf("Error printing migration plan: %s", err.Error())
+		return 1
+	}
+	if dryRun || len(plan.Migrations) == 0 {
+		return 0
+	}
+
+	records, err := mqBroker.ApplyMigrations(context.Background(), plan)
+	if printErr := printJSON(records); printErr != nil {
+		log.Errorf("Error printing migration records: %s", printErr.Error())
+	}
+	if err != nil {
+		log.Errorf("Error while applying broker topology migrations: %s", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// printJSON will print the given value to stdout as indented JSON.
+func printJSON(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+)
+
+// HealthCheck reports the health of a single component, along with any details worth exposing.
+type HealthCheck func() (healthy bool, details map[string]interface{})
+
+// Admin is the HTTP listener which exposes this service's operational data.
+type Admin struct {
+	config *config.Config
+	log    *logrus.Logger
+
+	mux *http.ServeMux
+
+	checksLock      sync.Mutex
+	checks          map[string]HealthCheck
+	readinessChecks map[string]HealthCheck
+}
+
+// New will build and return a new `Admin` instance.
+func New(cfg *config.Config, log *logrus.Logger) *Admin {
+	admin := &Admin{config: cfg, log: log, mux: http.NewServeMux(), checks: map[string]HealthCheck{}, readinessChecks: map[string]HealthCheck{}}
+	admin.mux.Handle("/debug/vars", expvar.Handler())
+	admin.mux.HandleFunc("/healthz", admin.handleHealth)
+	admin.mux.HandleFunc("/readyz", admin.handleReady)
+	return admin
+}
+
+// AddHealthCheck will register the given health check under the given name.
+//
+// The result of every registered check is served at `/healthz`.
+func (admin *Admin) AddHealthCheck(name string, check HealthCheck) {
+	admin.checksLock.Lock()
+	defer admin.checksLock.Unlock()
+
+	admin.checks[name] = check
+}
+
+// AddReadinessCheck will register the given readiness check under the given name.
+//
+// The result of every registered readiness & health check is served at `/readyz`.
+func (admin *Admin) AddReadinessCheck(name string, check HealthCheck) {
+	admin.checksLock.Lock()
+	defer admin.checksLock.Unlock()
+
+	admin.readinessChecks[name] = check
+}
+
+// Listen will make the admin listener listen on its configured port.
+//
+// This routine blocks for as long as the listener is running.
+func (admin *Admin) Listen() error {
+	admin.log.Infof("Admin listener is listening on '0.0.0.0:%d'.", admin.config.AdminPort)
+	return http.ListenAndServe(fmt.Sprintf(":%d", admin.config.AdminPort), admin.mux)
+}
+
+///////////////////////
+// Private Interface //
+
+// healthReport is the body served at `/healthz` & `/readyz`.
+type healthReport struct {
+	Healthy bool                   `json:"healthy"`
+	Checks  map[string]checkReport `json:"checks"`
+}
+
+// checkReport is the result of a single health check.
+type checkReport struct {
+	Healthy bool                   `json:"healthy"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// handleHealth will run all health checks, responding with `503` if any of them is unhealthy.
+func (admin *Admin) handleHealth(w http.ResponseWriter, r *http.Request) {
+	admin.checksLock.Lock()
+	report := runChecks(admin.checks)
+	admin.checksLock.Unlock()
+
+	admin.writeReport(w, report)
+}
+
+// handleReady will run all readiness & health checks, responding with `503` if any of them fails.
+func (admin *Admin) handleReady(w http.ResponseWriter, r *http.Request) {
+	admin.checksLock.Lock()
+	report := runChecks(admin.checks, admin.readinessChecks)
+	admin.checksLock.Unlock()
+
+	admin.writeReport(w, report)
+}
+
+// writeReport will write the given report, with a `503` status if it is unhealthy.
+func (admin *Admin) writeReport(w http.ResponseWriter, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		admin.log.Errorf("Error writing health report: %T: %s", err, err.Error())
+	}
+}
+
+// runChecks will run the given checks, and will return their combined report.
+func runChecks(checkSets ...map[string]HealthCheck) healthReport {
+	report := healthReport{Healthy: true, Checks: map[string]checkReport{}}
+	for _, checks := range checkSets {
+		for name, check := range checks {
+			healthy, details := check()
+			report.Checks[name] = checkReport{healthy, details}
+			report.Healthy = report.Healthy && healthy
+		}
+	}
+	return report
+}
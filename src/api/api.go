@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 
+	"gitlab.com/project-leaf/mq-service-go/src/auth"
 	"gitlab.com/project-leaf/mq-service-go/src/broker"
 	"gitlab.com/project-leaf/mq-service-go/src/config"
 	"gitlab.com/project-leaf/mq-service-go/src/internalService"
@@ -23,12 +24,19 @@ type API struct {
 }
 
 // New will build and return a new `API` instance.
-func New(cfg *config.Config, log *logrus.Logger, broker *broker.Broker) *API {
-	// Create the underlying gRPC server for this API.
-	grpcServer := grpc.NewServer()
+func New(cfg *config.Config, log *logrus.Logger, mqBroker *broker.Broker) *API {
+	// Create the underlying gRPC server for this API, authenticating the producers which call it.
+	authenticator := auth.New(cfg, log)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(authenticator.UnaryInterceptor))
+
+	// Publish through the asynchronous pipeline, if it is enabled.
+	var publisher broker.Publisher = mqBroker
+	if cfg.PublishQueueSize > 0 {
+		publisher = broker.NewPipeline(cfg, log, mqBroker)
+	}
 
 	// Register services.
-	internalMQService := internalService.New(cfg, log, broker)
+	internalMQService := internalService.New(cfg, log, publisher, mqBroker)
 	mq.RegisterInternalMQServiceServer(grpcServer, internalMQService)
 
 	return &API{cfg, log, grpcServer}
@@ -0,0 +1,317 @@
+package asyncapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"gitlab.com/project-leaf/mq-service-go/src/broker"
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+const (
+	// Version is the version of the AsyncAPI spec which generated documents conform to.
+	Version = "2.0.0"
+
+	// amqpBindingVersion is the version of the AsyncAPI AMQP bindings used by generated documents.
+	amqpBindingVersion = "0.2.0"
+
+	// systemEvent is the full name of the envelope which events are published in, unless encoded as CloudEvents.
+	systemEvent = "mq.SystemEvent"
+)
+
+// Document is an AsyncAPI document.
+type Document struct {
+	AsyncAPI   string             `json:"asyncapi"`
+	Info       Info               `json:"info"`
+	Servers    map[string]Server  `json:"servers,omitempty"`
+	Channels   map[string]Channel `json:"channels"`
+	Components Components         `json:"components"`
+	Exchanges  []Exchange         `json:"x-exchanges"` // The exchanges of the topology, starting with the `events` exchange.
+}
+
+// Info is the metadata of an AsyncAPI document.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a broker which the application described by an AsyncAPI document connects to.
+type Server struct {
+	URL      string `json:"url"`
+	Protocol string `json:"protocol"`
+}
+
+// Channel is a routing key of the exchange, along with the events published with it.
+type Channel struct {
+	Description string                 `json:"description,omitempty"`
+	Subscribe   *Operation             `json:"subscribe,omitempty"`
+	Bindings    map[string]interface{} `json:"bindings,omitempty"`
+	Queues      []Queue                `json:"x-queues,omitempty"` // The queues which the events may be routed to.
+}
+
+// Operation is an operation which consumers of a channel may perform.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Message     Reference              `json:"message"`
+	Bindings    map[string]interface{} `json:"bindings,omitempty"`
+}
+
+// Exchange is an exchange of the topology, along with the bindings of other exchanges to it.
+type Exchange struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Bindings []ExchangeBinding `json:"bindings,omitempty"`
+}
+
+// ExchangeBinding is the binding of a destination exchange to an exchange, by a routing key pattern.
+type ExchangeBinding struct {
+	Destination string `json:"destination"`
+	RoutingKey  string `json:"routingKey"`
+}
+
+// Queue is a queue which the events of a channel may be routed to, along with its binding.
+type Queue struct {
+	Name       string                 `json:"name"`
+	Exchange   string                 `json:"exchange"`
+	RoutingKey string                 `json:"routingKey,omitempty"` // Unless bound to a headers exchange.
+	Headers    map[string]interface{} `json:"headers,omitempty"`    // The `x-match` rules of a binding to a headers exchange.
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	TTLMs      int64                  `json:"ttlMs,omitempty"`
+}
+
+// Components are the reusable objects of an AsyncAPI document.
+type Components struct {
+	Messages map[string]Message `json:"messages"`
+	Schemas  map[string]Schema  `json:"schemas"`
+}
+
+// Message is an event message published to a channel.
+type Message struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ContentType string `json:"contentType"`
+	Headers     Schema `json:"headers"`
+	Payload     Schema `json:"payload"`
+}
+
+// Reference is a reference to a component of an AsyncAPI document.
+type Reference struct {
+	Ref string `json:"$ref"`
+}
+
+// Schema is a JSON schema.
+type Schema map[string]interface{}
+
+// Generate will generate an AsyncAPI document describing the given topology, the event types
+// which are published to it, and their messages as they are sent over the wire.
+//
+// Payloads are described per encoding: the `SystemEvent` envelope for `protobuf` & `json`, the
+// CloudEvents envelope for `cloudevents-structured`, and the bare event message for
+// `cloudevents-binary`, whose CloudEvents attributes are headers. Message schemas are derived from
+// the given descriptors, and describe messages as encoded in protobuf-JSON. The topology is
+// typically built with `broker.Describe`, and the descriptors with `mq.FileDescriptorSet`.
+func Generate(info Info, servers map[string]Server, topology *broker.Topology, set *descriptor.FileDescriptorSet) (*Document, error) {
+	schemas, err := newSchemaBuilder(set)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		AsyncAPI: Version,
+		Info:     info,
+		Servers:  servers,
+		Channels: map[string]Channel{},
+		Components: Components{
+			Messages: map[string]Message{},
+			Schemas:  schemas.schemas,
+		},
+	}
+
+	exchangeType := broker.ExchangeTypeEvents
+	for _, exchange := range topology.Exchanges {
+		described := Exchange{Name: exchange.Name, Type: exchange.Type}
+		for _, binding := range exchange.Bindings {
+			described.Bindings = append(described.Bindings, ExchangeBinding{binding.Destination, binding.RoutingKey})
+		}
+		doc.Exchanges = append(doc.Exchanges, described)
+		if exchange.Name == broker.ExchangeEvents {
+			exchangeType = exchange.Type
+		}
+	}
+	queues := map[string]broker.TopologyQueue{}
+	for _, queue := range topology.Queues {
+		queues[queue.Name] = queue
+	}
+
+	for _, event := range topology.Events {
+		eventType := event.Info
+		headers, payload, err := schemas.message(event, topology.RoutingHeaders)
+		if err != nil {
+			return nil, err
+		}
+		messageName := eventType.GetName()[strings.LastIndex(eventType.GetName(), ".")+1:]
+		message := Message{
+			Name:        messageName,
+			Title:       eventType.GetField(),
+			Description: fmt.Sprintf("Encoded as `%s`.", event.Encoding),
+			ContentType: eventType.GetContentType(),
+			Headers:     headers,
+			Payload:     payload,
+		}
+		if eventType.GetContentType() == mq.ContentTypeProtobuf {
+			message.Description += " The body is the binary protobuf encoding of the described message."
+		}
+		if event.Encrypted {
+			message.Description += " The body is encrypted with AES-256-GCM, and must be decrypted before it is decoded as described."
+		}
+		if event.Signed {
+			message.Description += " The event is signed with HMAC-SHA256, and its signature should be verified before it is decrypted or decoded."
+		}
+		doc.Components.Messages[messageName] = message
+
+		channel := Channel{
+			Description: fmt.Sprintf("`%s` events, published to the `%s` exchange with the routing key `%s`.", eventType.GetName(), broker.ExchangeEvents, eventType.GetRoutingKey()),
+			Subscribe: &Operation{
+				OperationID: "receive" + strings.TrimPrefix(messageName, "Event"),
+				Message:     Reference{"#/components/messages/" + messageName},
+				Bindings: map[string]interface{}{
+					"amqp": map[string]interface{}{
+						"deliveryMode":   2,
+						"mandatory":      true,
+						"bindingVersion": amqpBindingVersion,
+					},
+				},
+			},
+			Bindings: map[string]interface{}{
+				"amqp": map[string]interface{}{
+					"is": "routingKey",
+					"exchange": map[string]interface{}{
+						"name":       broker.ExchangeEvents,
+						"type":       exchangeType,
+						"durable":    true,
+						"autoDelete": false,
+					},
+					"bindingVersion": amqpBindingVersion,
+				},
+			},
+		}
+		for _, info := range eventType.GetQueues() {
+			queue := queues[info.GetName()]
+			channel.Queues = append(channel.Queues, Queue{
+				Name:       info.GetName(),
+				Exchange:   queue.Exchange,
+				RoutingKey: queue.RoutingKey,
+				Headers:    queue.Matching,
+				Arguments:  queue.Arguments,
+				TTLMs:      info.GetTtlMs(),
+			})
+		}
+		doc.Channels[eventType.GetRoutingKey()] = channel
+	}
+	return doc, nil
+}
+
+///////////////////////
+// Private Interface //
+
+// message will return the schemas of the headers & payload of the given event type's messages,
+// as published with its encoding.
+func (builder *schemaBuilder) message(event broker.TopologyEventType, routingHeaders []string) (Schema, Schema, error) {
+	eventType := event.Info
+	data, err := builder.reference(eventType.GetName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := map[string]interface{}{
+		mq.HeaderSchemaVersion: Schema{"type": "integer", "format": "int64", "const": eventType.GetSchemaVersion()},
+	}
+	required := []string{mq.HeaderSchemaVersion}
+	for _, header := range routingHeaders {
+		headers[header] = Schema{"type": "string"}
+	}
+	if event.Encrypted {
+		for _, header := range []string{mq.HeaderEncryptionAlgorithm, mq.HeaderEncryptionKeyID, mq.HeaderEncryptionWrappedKey} {
+			headers[header] = Schema{"type": "string"}
+			required = append(required, header)
+		}
+	}
+	if event.Signed {
+		for _, header := range []string{mq.HeaderSignature, mq.HeaderSignatureKeyID, mq.HeaderSignatureAlgorithm, mq.HeaderSignedHeaders} {
+			headers[header] = Schema{"type": "string"}
+			required = append(required, header)
+		}
+	}
+
+	var payload Schema
+	switch event.Encoding {
+	case config.EncodingCloudEventsStructured:
+		payload = Schema{
+			"type":       "object",
+			"properties": cloudEventAttributes(eventType.GetRoutingKey(), data),
+			"required":   []string{"specversion", "id", "source", "type", "time", "datacontenttype", "data"},
+		}
+
+	case config.EncodingCloudEventsBinary:
+		for name, attribute := range cloudEventAttributes(eventType.GetRoutingKey(), data) {
+			if name != "data" && name != "datacontenttype" {
+				headers[mq.CloudEventsHeaderPrefix+name] = attribute
+			}
+		}
+		for _, name := range []string{"specversion", "id", "source", "type", "time"} {
+			required = append(required, mq.CloudEventsHeaderPrefix+name)
+		}
+		payload = Schema{"$ref": data.Ref}
+
+	default:
+		envelope, err := builder.reference(systemEvent)
+		if err != nil {
+			return nil, nil, err
+		}
+		field, err := builder.jsonName(systemEvent, eventType.GetField())
+		if err != nil {
+			return nil, nil, err
+		}
+		// The envelope carries the event message in its `event` oneof field.
+		payload = Schema{
+			"allOf":    []interface{}{Schema{"$ref": envelope.Ref}},
+			"required": []string{field},
+		}
+	}
+
+	return Schema{"type": "object", "properties": headers, "required": required}, payload, nil
+}
+
+// jsonName will return the protobuf-JSON name of the given field of the message with the given full name.
+func (builder *schemaBuilder) jsonName(message, field string) (string, error) {
+	for _, candidate := range builder.messages[message].GetField() {
+		if candidate.GetName() == field {
+			return candidate.GetJsonName(), nil
+		}
+	}
+	return "", fmt.Errorf("unknown field '%s.%s'", message, field)
+}
+
+// cloudEventAttributes will return the schemas of the CloudEvents attributes of events with the
+// given routing key, whose data is described by the given reference.
+func cloudEventAttributes(routingKey string, data Reference) map[string]interface{} {
+	return map[string]interface{}{
+		"specversion":     Schema{"type": "string", "const": mq.CloudEventsSpecVersion},
+		"id":              Schema{"type": "string"},
+		"source":          Schema{"type": "string", "const": broker.CloudEventsSource},
+		"type":            Schema{"type": "string", "const": routingKey},
+		"time":            Schema{"type": "string", "format": "date-time"},
+		"datacontenttype": Schema{"type": "string", "const": mq.ContentTypeJSON},
+		"requestid":       Schema{"type": "string"},
+		"correlationid":   Schema{"type": "string"},
+		"causationid":     Schema{"type": "string"},
+		"producer":        Schema{"type": "string"},
+		"data":            Schema{"$ref": data.Ref},
+	}
+}
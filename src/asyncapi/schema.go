@@ -0,0 +1,143 @@
+package asyncapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// wellKnownSchemas are the schemas of the well-known types with special protobuf-JSON encodings.
+var wellKnownSchemas = map[string]Schema{
+	"google.protobuf.Timestamp": {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":  {"type": "string"},
+	"google.protobuf.Any":       {"type": "object"},
+	"google.protobuf.Struct":    {"type": "object"},
+	"google.protobuf.Value":     {},
+}
+
+// schemaBuilder builds the JSON schemas of proto messages, as encoded in protobuf-JSON.
+type schemaBuilder struct {
+	messages map[string]*descriptor.DescriptorProto     // Keyed by full name, without a leading dot.
+	enums    map[string]*descriptor.EnumDescriptorProto // Keyed by full name, without a leading dot.
+	schemas  map[string]Schema                          // The schemas built so far, keyed by full name.
+}
+
+// newSchemaBuilder will build and return a `schemaBuilder` for the messages of the given descriptors.
+func newSchemaBuilder(set *descriptor.FileDescriptorSet) (*schemaBuilder, error) {
+	builder := &schemaBuilder{
+		messages: map[string]*descriptor.DescriptorProto{},
+		enums:    map[string]*descriptor.EnumDescriptorProto{},
+		schemas:  map[string]Schema{},
+	}
+	for _, file := range set.GetFile() {
+		builder.index(file.GetPackage(), file.GetMessageType(), file.GetEnumType())
+	}
+	return builder, nil
+}
+
+// reference will build the schema of the message with the given full name, along with the
+// schemas of any messages it refers to, and will return a reference to it.
+func (builder *schemaBuilder) reference(name string) (Reference, error) {
+	ref := Reference{"#/components/schemas/" + name}
+	if _, ok := builder.schemas[name]; ok {
+		return ref, nil
+	}
+	message, ok := builder.messages[name]
+	if !ok {
+		return ref, fmt.Errorf("unknown message '%s'", name)
+	}
+
+	// Register the schema before building its fields, so that recursive messages terminate.
+	properties := map[string]interface{}{}
+	schema := Schema{"type": "object", "properties": properties}
+	builder.schemas[name] = schema
+	for _, field := range message.GetField() {
+		fieldSchema, err := builder.field(field)
+		if err != nil {
+			return ref, fmt.Errorf("%s.%s: %s", name, field.GetName(), err.Error())
+		}
+		properties[field.GetJsonName()] = fieldSchema
+	}
+	return ref, nil
+}
+
+///////////////////////
+// Private Interface //
+
+// index will index the given messages & enums, and those nested within them, by full name.
+func (builder *schemaBuilder) index(scope string, messages []*descriptor.DescriptorProto, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		builder.enums[scope+"."+enum.GetName()] = enum
+	}
+	for _, message := range messages {
+		name := scope + "." + message.GetName()
+		builder.messages[name] = message
+		builder.index(name, message.GetNestedType(), message.GetEnumType())
+	}
+}
+
+// field will return the schema of the given field.
+func (builder *schemaBuilder) field(field *descriptor.FieldDescriptorProto) (interface{}, error) {
+	typeName := strings.TrimPrefix(field.GetTypeName(), ".")
+
+	// Map fields are encoded as JSON objects.
+	if entry, ok := builder.messages[typeName]; ok && entry.GetOptions().GetMapEntry() {
+		value, err := builder.field(entry.GetField()[1])
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "object", "additionalProperties": value}, nil
+	}
+
+	schema, err := builder.single(field.GetType(), typeName)
+	if err != nil {
+		return nil, err
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return Schema{"type": "array", "items": schema}, nil
+	}
+	return schema, nil
+}
+
+// single will return the schema of a single value of the given type.
+func (builder *schemaBuilder) single(fieldType descriptor.FieldDescriptorProto_Type, typeName string) (interface{}, error) {
+	switch fieldType {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return Schema{"type": "number"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return Schema{"type": "integer", "format": "int32"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return Schema{"type": "integer", "format": "int32", "minimum": 0}, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		// 64-bit integers are encoded as strings in protobuf-JSON.
+		return Schema{"type": "string", "format": "int64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return Schema{"type": "boolean"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return Schema{"type": "string"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return Schema{"type": "string", "format": "byte"}, nil
+
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum, ok := builder.enums[typeName]
+		if !ok {
+			return nil, fmt.Errorf("unknown enum '%s'", typeName)
+		}
+		var values []string
+		for _, value := range enum.GetValue() {
+			values = append(values, value.GetName())
+		}
+		return Schema{"type": "string", "enum": values}, nil
+
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if schema, ok := wellKnownSchemas[typeName]; ok {
+			return schema, nil
+		}
+		return builder.reference(typeName)
+
+	default:
+		return nil, fmt.Errorf("unsupported field type '%s'", fieldType)
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+)
+
+// metadataAuthorization is the gRPC metadata key carrying a caller's bearer token.
+const metadataAuthorization = "authorization"
+
+// producerKey is the context key of an authenticated producer identity.
+type producerKey struct{}
+
+// Authenticator authenticates the producers calling this service.
+type Authenticator struct {
+	config *config.Config
+	log    *logrus.Logger
+}
+
+// New will build and return an `Authenticator` instance.
+func New(cfg *config.Config, log *logrus.Logger) *Authenticator {
+	return &Authenticator{cfg, log}
+}
+
+// UnaryInterceptor will authenticate the caller of each unary RPC by its bearer token, and will
+// make the caller's producer identity available to the handler. See `Producer`.
+//
+// If no producer tokens are configured, callers are not authenticated, and have no identity.
+//
+// Unauthenticated requests are rejected with an `Unauthenticated` gRPC status, rather than with a
+// `core.Error` in the response like every other failure, as they never reach a handler which could
+// build the response.
+func (authenticator *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if len(authenticator.config.ProducerTokens) == 0 {
+		return handler(ctx, req)
+	}
+
+	identity, ok := authenticator.authenticate(ctx)
+	if !ok {
+		authenticator.log.WithField("method", info.FullMethod).Warn("Rejecting unauthenticated request.")
+		return nil, status.Error(codes.Unauthenticated, "A valid producer token is required.")
+	}
+	return handler(WithProducer(ctx, identity), req)
+}
+
+// WithProducer will return a copy of the given context which carries the given producer identity.
+func WithProducer(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, producerKey{}, identity)
+}
+
+// Producer will return the authenticated producer identity carried by the given context, if any.
+func Producer(ctx context.Context) string {
+	identity, _ := ctx.Value(producerKey{}).(string)
+	return identity
+}
+
+///////////////////////
+// Private Interface //
+
+// authenticate will return the identity of the producer whose token was given in the request metadata.
+func (authenticator *Authenticator) authenticate(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[metadataAuthorization]) == 0 {
+		return "", false
+	}
+	token := md[metadataAuthorization][0]
+	if !strings.HasPrefix(token, "Bearer ") {
+		return "", false
+	}
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	// Compare against every token in constant time, so as not to leak how much of a token matched.
+	var identity string
+	for candidate, candidateToken := range authenticator.config.ProducerTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidateToken)) == 1 {
+			identity = candidate
+		}
+	}
+	return identity, identity != ""
+}
@@ -1,27 +1,47 @@
 package broker
 
 import (
+	"context"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 
 	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
 	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
 	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
 )
 
 const (
-	exchangeTypeTopic = "topic"
+	exchangeTypeTopic   = "topic"
+	exchangeTypeFanout  = "fanout"
+	exchangeTypeHeaders = "headers"
+
+	// dialTimeout bounds the time spent dialing & handshaking with a single broker node.
+	dialTimeout = 30 * time.Second
+	// heartbeat is the heartbeat interval requested of the broker.
+	heartbeat = 10 * time.Second
 
 	// ExchangeEvents is the exchange where event messages are published.
 	ExchangeEvents = "events"
-
-	queueEventsPhotoScanSampled     = "events.photoscan.sampled"
-	queueEventsPhotoScanSampledKey  = "events.photoscan.sampled"
-	queueEventsPhotoScanUploaded    = "events.photoscan.uploaded"
-	queueEventsPhotoScanUploadedKey = "events.photoscan.uploaded"
+	// ExchangeUnrouted is the alternate exchange of the `events` exchange, which receives the events
+	// no queue is bound to. It is only declared if unrouted events are kept. See `config.UnroutedEvents`.
+	ExchangeUnrouted = "events.unrouted"
+	// QueueUnrouted is the queue which keeps unrouted events for inspection.
+	QueueUnrouted = "events.unrouted"
+	// PolicyUnrouted is the broker policy which gives the `events` exchange its alternate exchange.
+	PolicyUnrouted = "mq-service-unrouted"
+	// MaxPriority is the highest priority an event may be published with.
+	MaxPriority = 9
+
+	// ExchangeTypeEvents is the type of the `events` exchange.
+	ExchangeTypeEvents = exchangeTypeTopic
 )
 
 var (
@@ -29,8 +49,6 @@ var (
 	//
 	// Probably need to lower this threshold in the future.
 	ttlSLA int64 = 1000 * 60 * 10
-
-	ttlTable amqp.Table
 )
 
 // Broker exposes an interface for managing connections to the backend message broker.
@@ -38,114 +56,487 @@ type Broker struct {
 	config *config.Config
 	log    *logrus.Logger
 
+	nodes     []node // The broker nodes to dial, in dial order.
+	nodeIndex int    // The index of the node currently in use, or the next to be dialed.
+	flow      *flowControl
+	circuit   *circuitBreaker
+
+	exchanges        []exchangeDeclaration // The exchanges, including the configured domain exchanges.
+	exchangeBindings []exchangeBinding     // The configured bindings between exchanges.
+	queues           []queueDeclaration    // The event queues, with their configured settings.
+
+	signingKeys    mq.SigningKeys    // The keys events may be signed with. See `config.EventSigningKeyID`.
+	encryptionKeys mq.EncryptionKeys // The master keys event data keys may be wrapped with. See `config.EventEncryptionKeyID`.
+	encrypted      map[string]bool   // The routing keys whose events are encrypted.
+
+	topology    topologyStatus // The result of the latest topology verification. See `VerifyTopology`.
+	verifier    verifier       // The connection which topology verifications run on. See `VerifyTopology`.
+	reconnected chan struct{}  // Signals the reconciler after every new connection. See `RunReconciler`.
+
+	lock       chan struct{} // Guards the fields below. See `acquire`.
 	connection *amqp.Connection
+	closed     chan *amqp.Error // Receives the error which closed `connection`, if any.
 	channel    *amqp.Channel
+	tracker    *confirmTracker // Routes the publisher confirms & returns of `channel`.
 }
 
 // New will build and return a `Broker` instance.
+//
+// NOTE: This is a failable constructor. If the configured exchange or header bindings, queue
+// settings, signing or encryption keys are not valid, this routine will panic.
 func New(cfg *config.Config, log *logrus.Logger) *Broker {
-	return &Broker{cfg, log, nil, nil}
+	nodes := make([]node, len(cfg.BrokerConnectionStrings))
+	for idx, url := range cfg.BrokerConnectionStrings {
+		nodes[idx] = newNode(url)
+	}
+
+	// Shuffle the nodes so that instances of this service spread their connections over the cluster.
+	if cfg.BrokerDialStrategy == config.DialShuffled {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		shuffled := make([]node, len(nodes))
+		for idx, from := range rng.Perm(len(nodes)) {
+			shuffled[idx] = nodes[from]
+		}
+		nodes = shuffled
+	}
+
+	queues, err := configureQueues(cfg)
+	if err != nil {
+		log.Panicf("Invalid header bindings: %s", err.Error()) // NOTE: routine may diverge here.
+	}
+	exchanges, exchangeBindings, err := configureExchanges(cfg, queues)
+	if err != nil {
+		log.Panicf("Invalid exchange bindings: %s", err.Error()) // NOTE: routine may diverge here.
+	}
+
+	signingKeys, err := mq.ParseSigningKeys(cfg.EventSigningKeys)
+	if err != nil {
+		log.Panicf("Invalid event signing keys: %s", err.Error()) // NOTE: routine may diverge here.
+	}
+
+	encryptionKeys, encrypted := mq.EncryptionKeys{}, map[string]bool{}
+	if len(cfg.EventEncryptionRoutingKeys) > 0 {
+		if encryptionKeys, err = mq.LoadEncryptionKeys(cfg.EventEncryptionKeyFile); err != nil {
+			log.Panicf("Invalid event encryption key file: %s", err.Error()) // NOTE: routine may diverge here.
+		}
+		if _, ok := encryptionKeys[cfg.EventEncryptionKeyID]; !ok {
+			log.Panicf("Event encryption key '%s' is not in the key file.", cfg.EventEncryptionKeyID) // NOTE: routine may diverge here.
+		}
+		for _, routingKey := range cfg.EventEncryptionRoutingKeys {
+			encrypted[routingKey] = true
+		}
+	}
+
+	broker := &Broker{
+		config:           cfg,
+		log:              log,
+		nodes:            nodes,
+		exchanges:        exchanges,
+		exchangeBindings: exchangeBindings,
+		queues:           queues,
+		flow:             newFlowControl(),
+		circuit:          newCircuitBreaker(log, cfg.BrokerBreakerThreshold, cfg.BrokerBreakerCooldown),
+		signingKeys:      signingKeys,
+		encryptionKeys:   encryptionKeys,
+		encrypted:        encrypted,
+		lock:             make(chan struct{}, 1),
+		reconnected:      make(chan struct{}, 1),
+		verifier:         verifier{lock: make(chan struct{}, 1)},
+	}
+
+	// Validate the exchange graph & queue settings, so that invalid settings are reported before the
+	// broker is talked to.
+	if err := broker.validateExchanges(); err != nil {
+		log.Panicf("Invalid exchange bindings: %s", err.GetMessage()) // NOTE: routine may diverge here.
+	}
+	if err := broker.validateQueues(); err != nil {
+		log.Panicf("Invalid queue settings: %s", err.GetMessage()) // NOTE: routine may diverge here.
+	}
+	return broker
+}
+
+// PublishOptions are the optional, per-event settings of a publishing.
+type PublishOptions struct {
+	// Producer is the authenticated identity of the service which produced the event, if any.
+	Producer string
+	// OccurredAt is when the event occurred. Defaults to the time of publishing.
+	OccurredAt time.Time
+	// CausationID is the ID of the request or event which directly caused the event. Defaults to
+	// the request ID.
+	CausationID string
+	// Priority is the priority of the event, from `0` (default) to `MaxPriority`. It may not exceed
+	// the max priority of the queues bound to the event's routing key.
+	Priority uint32
+	// Expiration is how long the event may wait in a queue before it expires. Defaults to the TTL
+	// of the queues bound to the event's routing key, which it may not exceed.
+	Expiration time.Duration
 }
 
 // EnsureTopology will ensure the needed topology is in place in the broker.
 //
 // This routine should only be called once when the service is first started.
-func (broker *Broker) EnsureTopology() *core.Error {
-	broker.log.Info("Ensuring broker topology.")
-	chn, _, chnErr := broker.getChannel()
-	if chnErr != nil {
-		return broker.handleError(chnErr)
+func (broker *Broker) EnsureTopology(ctx context.Context) *core.Error {
+	call, err := broker.circuit.allow()
+	if err != nil {
+		return err
+	}
+	err = broker.ensureTopology(ctx)
+	broker.circuit.record(call, err)
+	if err != nil {
+		return err
 	}
 
-	// Ensure needed exchanges.
-	if err := chn.ExchangeDeclare(ExchangeEvents, exchangeTypeTopic, true, false, false, false, nil); err != nil {
-		return broker.handleError(err)
+	// The alternate exchange is given by a policy, as the `events` exchange may have been declared
+	// without one. It is set through the management API, so outside of the broker's lock.
+	if broker.config.UnroutedEvents {
+		if err := broker.applyUnroutedPolicy(ctx); err != nil {
+			return core.NewFromError(err, broker.log)
+		}
 	}
+	return nil
+}
 
-	// Ensure PhotoScanUploaded queue.
-	if _, err := chn.QueueDeclare(queueEventsPhotoScanUploaded, true, false, false, false, amqp.Table{"x-message-ttl": ttlSLA}); err != nil {
-		return broker.handleError(err)
+// PublishEvent will publish the given `SystemEventMessage` to the `events` exchange.
+//
+// The event is stamped with a new unique event ID, which is also its AMQP `MessageId`. The request
+// ID of the given `core.Context` is the event's correlation ID.
+//
+// This routine will not return until the broker has confirmed the publishing. If the given
+// context is cancelled or its deadline passes first, or if the broker blocks the connection for
+// longer than `BROKER_BLOCKED_TIMEOUT` meanwhile, the operation is aborted.
+//
+// Invalid options are rejected with an `INVALID_ARGUMENT` error before anything else. While the
+// circuit breaker is open, this routine fails right away with a `CIRCUIT_OPEN` error. If a queue
+// which rejects publishings once full nacks the event, a retryable `QUEUE_FULL` error naming the
+// queue is returned.
+func (broker *Broker) PublishEvent(ctx context.Context, message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions) *core.Error {
+	route, err := broker.routeEvent(message, reqCtx, opts)
+	if err != nil {
+		return err
+	}
+	call, err := broker.circuit.allow()
+	if err != nil {
+		return err
+	}
+	err = broker.publishEvent(ctx, message, reqCtx, opts, route)
+	broker.circuit.record(call, err)
+	return err
+}
+
+// HealthCheck will report the health of the broker connection, as seen by this service.
+//
+// The connection is unhealthy while the broker has blocked it, or while the circuit breaker is open.
+func (broker *Broker) HealthCheck() (bool, map[string]interface{}) {
+	state := broker.flow.state()
+	circuit := broker.circuit.currentState()
+	details := map[string]interface{}{
+		"node":    metrics.BrokerNode.Value(),
+		"blocked": state.blocked,
+		"circuit": circuit.String(),
+	}
+	if state.blocked {
+		details["blockedReason"] = state.reason
+		details["blockedSince"] = state.since
+	}
+	return !state.blocked && circuit != circuitOpen, details
+}
+
+///////////////////////
+// Private Interface //
+
+// ensureTopology is the implementation of `EnsureTopology`.
+func (broker *Broker) ensureTopology(ctx context.Context) *core.Error {
+	if err := broker.acquire(ctx); err != nil {
+		return core.NewErrorFromContext(err)
 	}
-	if err := chn.QueueBind(queueEventsPhotoScanUploaded, queueEventsPhotoScanUploadedKey, ExchangeEvents, false, nil); err != nil {
-		return broker.handleError(err)
+	defer broker.release()
+
+	broker.log.Info("Ensuring broker topology.")
+	chn, _, chnErr := broker.getChannel(ctx)
+	if chnErr != nil {
+		return broker.handleError(ctx, chnErr)
 	}
 
-	// Ensure PhotoScanSampled queue.
-	if _, err := chn.QueueDeclare(queueEventsPhotoScanSampled, true, false, false, false, amqp.Table{"x-message-ttl": ttlSLA}); err != nil {
-		return broker.handleError(err)
+	// Ensure needed exchanges, & the bindings of domain exchanges.
+	for _, exchange := range broker.exchanges {
+		if err := exchange.declare(chn); err != nil {
+			return broker.handleError(ctx, err)
+		}
 	}
-	if err := chn.QueueBind(queueEventsPhotoScanSampled, queueEventsPhotoScanSampledKey, ExchangeEvents, false, nil); err != nil {
-		return broker.handleError(err)
+	for _, binding := range broker.exchangeBindings {
+		if err := binding.declare(chn); err != nil {
+			return broker.handleError(ctx, err)
+		}
+	}
+
+	// Ensure a queue for each event type, bound to its routing key, & the queue of unrouted events.
+	for _, queue := range broker.queues {
+		if err := queue.declare(chn); err != nil {
+			return broker.handleError(ctx, err)
+		}
 	}
 
 	broker.log.Info("Broker topology is ready.")
 	return nil
 }
 
-// PublishEvent will publish the given `SystemEventMessage` to the `events` exchange.
-func (broker *Broker) PublishEvent(message mq.SystemEventMessage, ctx *core.Context) *core.Error {
-	chn, _, chnErr := broker.getChannel()
-	if chnErr != nil {
-		broker.log.Errorf("Error getting channel: %T: %s", chnErr, chnErr.Error())
-		return broker.handleError(chnErr)
+// publishEvent is the implementation of `PublishEvent`, publishing the given event along the given
+// route. See `routeEvent`.
+//
+// The broker's lock is only held while the event is published, so that several publishings may
+// await their confirms at once.
+func (broker *Broker) publishEvent(ctx context.Context, message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions, route eventRoute) *core.Error {
+	eventType := mq.EventTypeOf(message)
+
+	// Stamp the event's metadata.
+	now := time.Now()
+	occurredAt := opts.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = now
+	}
+	occurredAtProto, err := ptypes.TimestampProto(occurredAt)
+	if err != nil {
+		return core.NewInvalidArgument("occurredAt", "The occurred-at time is out of range.")
+	}
+	correlationID := reqCtx.GetRequestid()
+	causationID := opts.CausationID
+	if causationID == "" {
+		causationID = correlationID
 	}
 
 	// Build the event wrapper.
 	event := &mq.SystemEvent{
-		Context: ctx,
-		Event:   message,
-	}
-
-	// Marshal the given protobuf message to bytes.
-	data, dataErr := proto.Marshal(event)
-	if dataErr != nil {
-		broker.log.Errorf("Error marshalling protobuf message: %T: %s", dataErr, dataErr.Error())
-		return core.NewError500()
+		Context:       reqCtx,
+		Event:         message,
+		SchemaVersion: eventType.SchemaVersion,
+		Id:            newEventID(),
+		OccurredAt:    occurredAtProto,
+		Producer:      opts.Producer,
+		CorrelationId: correlationID,
+		CausationId:   causationID,
 	}
 
 	// Construct the AMQP segment to be sent over the wire.
 	msg := amqp.Publishing{
-		ContentType:  "application/protobuf",
-		DeliveryMode: amqp.Persistent,
-		Timestamp:    time.Now(),
-		Type:         message.RoutingKey(),
-		AppId:        "mq-service",
-		Body:         data,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     event.Id,
+		CorrelationId: correlationID,
+		Timestamp:     now,
+		Type:          message.RoutingKey(),
+		AppId:         "mq-service",
+		Headers:       amqp.Table{mq.HeaderSchemaVersion: int64(eventType.SchemaVersion)},
+		Priority:      uint8(opts.Priority),
+	}
+	if opts.Expiration > 0 {
+		msg.Expiration = strconv.FormatInt(int64(opts.Expiration/time.Millisecond), 10)
 	}
 
-	// Publish the event.
-	if err := chn.Publish(ExchangeEvents, message.RoutingKey(), true, false, msg); err != nil {
-		broker.log.Errorf("Error publishing event: %T: %s", err, err.Error())
-		return broker.handleError(err)
+	// Copy the routing headers of the event into the segment.
+	for header, value := range route.headers {
+		msg.Headers[header] = value
+	}
+
+	// Encode the event into the segment, as configured for its routing key.
+	if err := encodeEvent(broker.encodingFor(msg.Type), event, &msg); err != nil {
+		broker.log.Errorf("Error encoding event: %T: %s", err, err.Error())
+		return core.NewError(core.CodeInternal)
+	}
+	if err := broker.compressBody(&msg); err != nil {
+		broker.log.Errorf("Error compressing event: %T: %s", err, err.Error())
+		return core.NewError(core.CodeInternal)
+	}
+
+	// Encrypt the event, if enabled for its routing key.
+	if broker.encrypted[msg.Type] {
+		keyID := broker.config.EventEncryptionKeyID
+		if err := mq.Encrypt(keyID, broker.encryptionKeys[keyID], &msg); err != nil {
+			broker.log.Errorf("Error encrypting event: %T: %s", err, err.Error())
+			return core.NewError(core.CodeInternal)
+		}
+	}
+
+	// Sign the event, if signing is enabled. This must be the last change made to the segment.
+	if keyID := broker.config.EventSigningKeyID; keyID != "" {
+		mq.Sign(keyID, broker.signingKeys[keyID], &msg)
+	}
+
+	// Publish the event. A blocking of the connection from here on bounds the wait for its confirm.
+	if err := broker.awaitUnblocked(ctx); err != nil {
+		return err
+	}
+	blocking := broker.flow.state().blocking
+	chn, outcome, sendErr := broker.send(ctx, msg.Type, msg)
+	if sendErr != nil {
+		return sendErr
+	}
+
+	// Wait for the broker to confirm the publishing.
+	result, confirmErr := broker.awaitConfirm(ctx, chn, outcome, blocking)
+	if confirmErr != nil {
+		return confirmErr
+	}
+	if !result.confirm.Ack {
+		// Queues which reject publishings once full nack them, so that publishers can back off.
+		if queues := rejectingQueues(route.queues); len(queues) > 0 {
+			broker.log.WithField("queues", queues).Warnf("Event publishing was nacked by the broker, as a queue is full: delivery tag %d.", result.confirm.DeliveryTag)
+			for _, queue := range queues {
+				metrics.QueueFullRejections.Add(queue, 1)
+			}
+			coreErr := core.NewError(core.CodeQueueFull)
+			coreErr.Meta[core.MetaQueue] = strings.Join(queues, ",")
+			return coreErr
+		}
+		broker.log.Errorf("Event publishing was nacked by the broker: delivery tag %d.", result.confirm.DeliveryTag)
+		return core.NewError(core.CodeBrokerUnavailable)
+	}
+
+	// An unroutable publishing is returned by the broker before it is confirmed. While unrouted
+	// events are kept, events are only unroutable if the alternate exchange policy is missing.
+	if ret := result.returned; ret != nil {
+		broker.log.WithFields(logrus.Fields{
+			"exchange":   ret.Exchange,
+			"routingKey": ret.RoutingKey,
+			"replyCode":  ret.ReplyCode,
+		}).Errorf("Event publishing was returned by the broker: %s", ret.ReplyText)
+		coreErr := core.NewError(core.CodeUnroutable)
+		coreErr.Meta[core.MetaReplyCode] = strconv.Itoa(int(ret.ReplyCode))
+		return coreErr
 	}
 
 	return nil
 }
 
-///////////////////////
-// Private Interface //
+// send will publish the given segment to the `events` exchange with the given routing key, on the
+// current channel. It will return the channel, along with a channel receiving the outcome of the
+// publishing once the broker confirms it.
+func (broker *Broker) send(ctx context.Context, routingKey string, msg amqp.Publishing) (*amqp.Channel, <-chan publishOutcome, *core.Error) {
+	if err := broker.acquire(ctx); err != nil {
+		return nil, nil, core.NewErrorFromContext(err)
+	}
+	defer broker.release()
+
+	chn, _, chnErr := broker.getChannel(ctx)
+	if chnErr != nil {
+		broker.log.Errorf("Error getting channel: %T: %s", chnErr, chnErr.Error())
+		return nil, nil, broker.handleError(ctx, chnErr)
+	}
+
+	outcome := broker.tracker.register(msg.MessageId)
+	if err := chn.Publish(ExchangeEvents, routingKey, true, false, msg); err != nil {
+		broker.log.Errorf("Error publishing event: %T: %s", err, err.Error())
+		return nil, nil, broker.handleError(ctx, err)
+	}
+	return chn, outcome, nil
+}
+
+// awaitConfirm will wait for the outcome of a publishing on the given channel.
+//
+// If the given context is done first, or if the connection is blocked (see the given channel) and
+// stays so for `BROKER_BLOCKED_TIMEOUT`, the wait is given up. A blocked broker stops reading from
+// the connection, so the confirm may otherwise never come. The confirm of a publishing which is
+// given up on is discarded once it arrives.
+func (broker *Broker) awaitConfirm(ctx context.Context, chn *amqp.Channel, outcome <-chan publishOutcome, blocking <-chan struct{}) (publishOutcome, *core.Error) {
+	var blockedTimeout <-chan time.Time
+	for {
+		select {
+		case result, ok := <-outcome:
+			if !ok {
+				broker.log.Error("Channel closed while awaiting publisher confirm.")
+				return result, broker.handleClosedChannel(ctx, chn)
+			}
+			return result, nil
+
+		case <-ctx.Done():
+			broker.log.Warnf("Gave up awaiting publisher confirm: %s", ctx.Err().Error())
+			return publishOutcome{}, core.NewErrorFromContext(ctx.Err())
+
+		case <-blocking:
+			blocking = nil
+			timer := time.NewTimer(broker.config.BrokerBlockedTimeout)
+			defer timer.Stop()
+			blockedTimeout = timer.C
+
+		case <-blockedTimeout:
+			state := broker.flow.state()
+			broker.log.WithField("reason", state.reason).Warn("Gave up awaiting publisher confirm while the broker has blocked the connection.")
+			return publishOutcome{}, newBlockedError(state.reason)
+		}
+	}
+}
+
+// handleClosedChannel will handle the given channel having been closed while a publishing awaited
+// its confirm on it.
+//
+// The receiver is only reset if the channel is still its current one, as another publishing may
+// already have replaced it.
+func (broker *Broker) handleClosedChannel(ctx context.Context, chn *amqp.Channel) *core.Error {
+	if err := broker.acquire(ctx); err != nil {
+		return core.NewFromError(amqp.ErrClosed, broker.log)
+	}
+	defer broker.release()
+
+	if broker.channel != chn {
+		return core.NewFromError(amqp.ErrClosed, broker.log)
+	}
+	return broker.handleError(ctx, amqp.ErrClosed)
+}
 
 // getConnection ensure a live connection to the broker exists and will return it.
 //
 // **This routine will mutate the receiver if a connection is established successfully.**
 // In addition to mutating the receiver, it will also return the established connection
 // to the caller.
-func (broker *Broker) getConnection() (*amqp.Connection, error) {
+//
+// Starting with the node currently in use, each configured node is dialed in turn until a
+// connection is established. Nodes which can not be dialed are skipped. Dialing is aborted if the
+// given context is done first.
+func (broker *Broker) getConnection(ctx context.Context) (*amqp.Connection, error) {
 	// If a live connection already exists, then return in.
 	if broker.connection != nil {
-		return broker.connection, nil
+		if !broker.connectionLost() {
+			return broker.connection, nil
+		}
+		broker.reset()
 	}
 
-	// Dial a new connection.
-	broker.log.Info("Establishing broker connection.")
-	conn, dialErr := amqp.Dial(broker.config.BrokerConnectionString)
-	if dialErr != nil {
-		return nil, dialErr
+	// Dial a new connection, moving through the nodes until one is healthy.
+	var dialErr error
+	for attempt := 0; attempt < len(broker.nodes); attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		node := broker.nodes[broker.nodeIndex]
+		broker.log.WithField("node", node.name).Info("Establishing broker connection.")
+
+		conn, err := amqp.DialConfig(node.url, amqp.Config{Heartbeat: heartbeat, Locale: "en_US", Dial: dialContext(ctx)})
+		if err != nil {
+			// A dial aborted by the caller says nothing about the health of the node.
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			broker.log.WithField("node", node.name).Warnf("Failed to dial broker node: %T: %s", err, err.Error())
+			metrics.BrokerDialFailures.Add(node.name, 1)
+			dialErr = err
+			broker.nextNode()
+			continue
+		}
+
+		// Mutate receiver by updating its `connection` field, and return.
+		broker.log.WithField("node", node.name).Info("Broker connection established.")
+		metrics.BrokerNode.Set(node.name)
+		metrics.BrokerConnections.Add(node.name, 1)
+		broker.connection = conn
+		broker.closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+		broker.flow.attach(conn)
+		metrics.BrokerBlocked.Set(0)
+		go broker.watchBlocked(node, conn, conn.NotifyBlocked(make(chan amqp.Blocking, 1)))
+		broker.signalReconnect()
+		return conn, nil
 	}
 
-	// Mutate receiver by updating its `connection` field, and return.
-	broker.connection = conn
-	return conn, nil
+	metrics.BrokerNode.Set("")
+	return nil, dialErr
 }
 
 // getChannel will ensure an open channel to the broker exists and will return it.
@@ -153,9 +544,11 @@ func (broker *Broker) getConnection() (*amqp.Connection, error) {
 // **This routine will mutate the receiver if a channel is established successfully.**
 // In addition to mutating the receiver, it will also return the opened channel and the
 // parent connection to the caller.
-func (broker *Broker) getChannel() (*amqp.Channel, *amqp.Connection, error) {
+//
+// Channels are put into confirm mode, so that publishings can be confirmed by the broker.
+func (broker *Broker) getChannel(ctx context.Context) (*amqp.Channel, *amqp.Connection, error) {
 	// Ensure we have a working connection.
-	conn, connErr := broker.getConnection()
+	conn, connErr := broker.getConnection(ctx)
 	if connErr != nil {
 		return nil, nil, connErr
 	}
@@ -169,13 +562,18 @@ func (broker *Broker) getChannel() (*amqp.Channel, *amqp.Connection, error) {
 	broker.log.Info("Opening broker channel.")
 	chn, chnErr := conn.Channel()
 	if chnErr != nil {
-		conn.Close()
-		broker.connection = nil // Mutates receiver.
+		broker.reset() // Mutates receiver.
 		return nil, nil, chnErr
 	}
+	if err := chn.Confirm(false); err != nil {
+		chn.Close()
+		broker.reset() // Mutates receiver.
+		return nil, nil, err
+	}
 
-	// Mutate receiver by updating its `channel` field, and return.
+	// Mutate receiver by updating its `channel` & `tracker` fields, and return.
 	broker.channel = chn
+	broker.tracker = newConfirmTracker(chn)
 	return chn, conn, nil
 }
 
@@ -186,18 +584,109 @@ func (broker *Broker) getChannel() (*amqp.Channel, *amqp.Connection, error) {
 // channel. This will ensure that connections can be re-eastablished and channels re-opened.
 //
 // This routine will also take the given error and construct an error from it which can be
-// more directly used in this service.
-func (broker *Broker) handleError(err error) *core.Error {
+// more directly used in this service. Errors caused by the given context being done say nothing
+// about the state of the broker, and leave the receiver untouched.
+func (broker *Broker) handleError(ctx context.Context, err error) *core.Error {
+	if ctxErr := ctx.Err(); ctxErr != nil && err == ctxErr {
+		return core.NewErrorFromContext(ctxErr)
+	}
+
 	// Put the broker back into a pristine state so that it
 	// can handle connection &| channel issues.
-	if broker.channel != nil {
-		broker.channel.Close()
-		broker.channel = nil
+	broker.connectionLost()
+	broker.reset()
+
+	return core.NewFromError(err, broker.log)
+}
+
+// connectionLost will check if the current connection has been closed by the broker or the network.
+//
+// If it has, the loss is recorded and the receiver is moved to the next node, so that the next
+// dial goes to a different node of the cluster.
+func (broker *Broker) connectionLost() bool {
+	if broker.connection == nil {
+		return false
+	}
+
+	select {
+	case closeErr, ok := <-broker.closed:
+		node := broker.nodes[broker.nodeIndex]
+		if ok && closeErr != nil {
+			broker.log.WithField("node", node.name).Errorf("Lost broker connection: %s", closeErr.Error())
+		} else {
+			broker.log.WithField("node", node.name).Error("Lost broker connection.")
+		}
+		metrics.BrokerConnectionsLost.Add(node.name, 1)
+		broker.nextNode()
+		return true
+	default:
+		return false
 	}
+}
+
+// reset will close any open channel & connection, putting the receiver back into a pristine state.
+func (broker *Broker) reset() {
+	broker.resetChannel()
 	if broker.connection != nil {
 		broker.connection.Close()
 		broker.connection = nil
+		broker.closed = nil
+	}
+}
+
+// resetChannel will close any open channel, so that a fresh one is opened on next use.
+func (broker *Broker) resetChannel() {
+	if broker.channel != nil {
+		broker.channel.Close()
+		broker.channel = nil
+		broker.tracker = nil
+	}
+}
+
+// acquire will acquire the receiver's lock, unless the given context is done first.
+//
+// Every public method which uses the internal connection &| channel must hold the lock while
+// doing so, and must call `release` once finished.
+func (broker *Broker) acquire(ctx context.Context) error {
+	select {
+	case broker.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
+
+// release will release the receiver's lock.
+func (broker *Broker) release() {
+	<-broker.lock
+}
 
-	return core.New500FromError(err, broker.log)
+// nextNode will move the receiver to the next broker node, round-robin.
+func (broker *Broker) nextNode() {
+	broker.nodeIndex = (broker.nodeIndex + 1) % len(broker.nodes)
+}
+
+// dialContext will build a dial func for `amqp.Config` which is bound to the given context.
+//
+// The returned connection has a deadline set for TLS & AMQP handshaking, which is the sooner of
+// `dialTimeout` and the context's deadline. It is cleared by `amqp` once the handshake completes.
+func dialContext(ctx context.Context) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(dialTimeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
 }
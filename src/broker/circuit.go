@@ -0,0 +1,190 @@
+package broker
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+// circuitState is the state of a `circuitBreaker`.
+type circuitState int
+
+const (
+	// circuitClosed lets all operations through.
+	circuitClosed circuitState = iota
+	// circuitOpen rejects all operations until the cool-down has passed.
+	circuitOpen
+	// circuitHalfOpen lets a single trial operation through, which decides whether the circuit closes or re-opens.
+	circuitHalfOpen
+)
+
+func (state circuitState) String() string {
+	switch state {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// failureCodes are the error codes which show that the broker is unavailable.
+var failureCodes = map[string]bool{
+	core.CodeBrokerUnavailable: true,
+	core.CodeTimeout:           true,
+}
+
+// roundTripCodes are the error codes which are replied by the broker itself, and so show that it is
+// available.
+var roundTripCodes = map[string]bool{
+	core.CodeAccessRefused:      true,
+	core.CodeQueueFull:          true,
+	core.CodeUnroutable:         true,
+	core.CodeNotFound:           true,
+	core.CodePreconditionFailed: true,
+}
+
+// circuitBreaker stops operations against the broker while it appears to be down.
+//
+// After `threshold` consecutive failures the circuit opens, and operations are rejected right away.
+// Once `cooldown` has passed, a single trial operation is let through. If it succeeds the circuit
+// closes, else it opens again for another cool-down.
+type circuitBreaker struct {
+	log       *logrus.Logger
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int       // Consecutive failures seen while closed.
+	openedAt time.Time // When the circuit last opened.
+	trial    bool      // Whether a trial operation is in flight while half-open.
+}
+
+// newCircuitBreaker will build and return a new, closed `circuitBreaker` instance.
+//
+// A threshold of `0` disables the circuit breaker.
+func newCircuitBreaker(log *logrus.Logger, threshold int, cooldown time.Duration) *circuitBreaker {
+	metrics.BrokerCircuitState.Set(circuitClosed.String())
+	return &circuitBreaker{log: log, threshold: threshold, cooldown: cooldown}
+}
+
+// circuitCall is an operation let through by a `circuitBreaker`, whose outcome is given to `record`.
+type circuitCall struct {
+	trial bool // Whether the operation is the trial of a half-open circuit.
+}
+
+// allow will check if an operation may proceed, returning a `CIRCUIT_OPEN` error if not.
+//
+// Every allowed operation must have its outcome given to `record`, along with the returned call.
+func (cb *circuitBreaker) allow() (circuitCall, *core.Error) {
+	if cb.threshold == 0 {
+		return circuitCall{}, nil
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		retryAfter := cb.openedAt.Add(cb.cooldown).Sub(time.Now())
+		if retryAfter > 0 {
+			return circuitCall{}, newCircuitOpenError(retryAfter)
+		}
+		cb.transition(circuitHalfOpen)
+		cb.trial = true
+		return circuitCall{trial: true}, nil
+
+	case circuitHalfOpen:
+		if cb.trial {
+			return circuitCall{}, newCircuitOpenError(0)
+		}
+		cb.trial = true
+		return circuitCall{trial: true}, nil
+
+	default:
+		return circuitCall{}, nil
+	}
+}
+
+// record will update the circuit with the outcome of the given allowed operation.
+//
+// Only errors showing that the broker is unavailable count as failures, and only operations which
+// got a reply from the broker count as successes. Any other outcome leaves the circuit as it is,
+// though a half-open circuit will let another trial operation through.
+//
+// While half-open, only the outcome of the trial operation is taken into account.
+func (cb *circuitBreaker) record(call circuitCall, err *core.Error) {
+	if cb.threshold == 0 {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	failed := err != nil && failureCodes[err.Code]
+	succeeded := err == nil || roundTripCodes[err.Code]
+
+	switch cb.state {
+	case circuitHalfOpen:
+		if !call.trial {
+			return
+		}
+		cb.trial = false
+		if failed {
+			cb.open()
+		} else if succeeded {
+			cb.transition(circuitClosed)
+			cb.failures = 0
+		}
+
+	case circuitClosed:
+		if failed {
+			cb.failures++
+			if cb.failures >= cb.threshold {
+				cb.open()
+			}
+		} else if succeeded {
+			cb.failures = 0
+		}
+	}
+}
+
+// currentState will return the current state of the circuit.
+func (cb *circuitBreaker) currentState() circuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// open will open the circuit. The caller must hold the receiver's lock.
+func (cb *circuitBreaker) open() {
+	cb.transition(circuitOpen)
+	cb.openedAt = time.Now()
+	cb.failures = 0
+}
+
+// transition will move the circuit to the given state. The caller must hold the receiver's lock.
+func (cb *circuitBreaker) transition(state circuitState) {
+	cb.log.WithFields(logrus.Fields{
+		"from": cb.state.String(),
+		"to":   state.String(),
+	}).Warn("Broker circuit breaker changed state.")
+	metrics.BrokerCircuitState.Set(state.String())
+	metrics.BrokerCircuitTransitions.Add(state.String(), 1)
+	cb.state = state
+}
+
+// newCircuitOpenError will build a `CIRCUIT_OPEN` error, hinting when to retry.
+func newCircuitOpenError(retryAfter time.Duration) *core.Error {
+	err := core.NewError(core.CodeCircuitOpen)
+	err.Meta["retry_after_ms"] = strconv.FormatInt(int64(retryAfter/time.Millisecond), 10)
+	return err
+}
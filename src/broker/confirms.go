@@ -0,0 +1,140 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// confirmTracker routes the publisher confirms of a channel to the publishings awaiting them, by
+// delivery tag, so that several publishings may await their confirms at once.
+//
+// Delivery tags are assigned in publishing order, starting at `1` once the channel is put into
+// confirm mode. Publishings must therefore be registered & published in the same order, which the
+// receiver's owner ensures by holding the broker's lock while doing both.
+type confirmTracker struct {
+	mu        sync.Mutex
+	published uint64                     // The delivery tag of the latest registered publishing.
+	pending   map[uint64]*pendingConfirm // The publishings awaiting their confirm, by delivery tag.
+	returned  map[string]amqp.Return     // The returns of pending publishings, by message ID.
+	closed    bool                       // Whether the channel has been closed.
+}
+
+// pendingConfirm is a single publishing awaiting its confirm.
+type pendingConfirm struct {
+	messageID string
+	outcome   chan publishOutcome // Receives the outcome of the publishing. Closed if the channel closes first.
+}
+
+// publishOutcome is the broker's response to a single publishing.
+type publishOutcome struct {
+	confirm  amqp.Confirmation
+	returned *amqp.Return // The publishing, if the broker returned it as unroutable.
+}
+
+// newConfirmTracker will build and return a `confirmTracker` for the given channel, which must be
+// in confirm mode, and will start routing its confirms & returns.
+func newConfirmTracker(chn *amqp.Channel) *confirmTracker {
+	tracker := &confirmTracker{pending: map[uint64]*pendingConfirm{}, returned: map[string]amqp.Return{}}
+	confirms := chn.NotifyPublish(make(chan amqp.Confirmation, 1))
+	returns := chn.NotifyReturn(make(chan amqp.Return, 1))
+	go tracker.dispatch(confirms, returns)
+	return tracker
+}
+
+// register will register the next publishing on the channel, returning a channel which receives
+// its outcome. It must be called right before the publishing is published.
+func (tracker *confirmTracker) register(messageID string) <-chan publishOutcome {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	outcome := make(chan publishOutcome, 1)
+	if tracker.closed {
+		close(outcome)
+		return outcome
+	}
+	tracker.published++
+	tracker.pending[tracker.published] = &pendingConfirm{messageID, outcome}
+	return outcome
+}
+
+///////////////////////
+// Private Interface //
+
+// dispatch will route the given confirms & returns to the pending publishings, until the channel closes.
+//
+// This routine is meant to be run in its own goroutine.
+func (tracker *confirmTracker) dispatch(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			tracker.stash(ret)
+
+		case confirm, ok := <-confirms:
+			if !ok {
+				tracker.close()
+				return
+			}
+
+			// The broker returns an unroutable publishing before confirming it, so any return of this
+			// publishing has already been sent to `returns` by now.
+		drain:
+			for {
+				select {
+				case ret, ok := <-returns:
+					if !ok {
+						returns = nil
+						break drain
+					}
+					tracker.stash(ret)
+				default:
+					break drain
+				}
+			}
+			tracker.resolve(confirm)
+		}
+	}
+}
+
+// stash will keep the given return until the confirm of its publishing arrives.
+func (tracker *confirmTracker) stash(ret amqp.Return) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.returned[ret.MessageId] = ret
+}
+
+// resolve will hand the given confirm, along with any return of its publishing, to the publishing.
+func (tracker *confirmTracker) resolve(confirm amqp.Confirmation) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	pending, ok := tracker.pending[confirm.DeliveryTag]
+	if !ok {
+		return
+	}
+	delete(tracker.pending, confirm.DeliveryTag)
+
+	outcome := publishOutcome{confirm: confirm}
+	if ret, ok := tracker.returned[pending.messageID]; ok {
+		outcome.returned = &ret
+		delete(tracker.returned, pending.messageID)
+	}
+	pending.outcome <- outcome // Buffered, & only ever sent to once.
+}
+
+// close will mark the channel as closed, closing the outcome channels of all pending publishings.
+func (tracker *confirmTracker) close() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.closed = true
+	for tag, pending := range tracker.pending {
+		close(pending.outcome)
+		delete(tracker.pending, tag)
+	}
+}
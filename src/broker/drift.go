@@ -0,0 +1,558 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+const (
+	// DriftMissing is the `Drift` issue of an entity which the broker does not have.
+	DriftMissing = "missing"
+	// DriftMismatched is the `Drift` issue of an entity which the broker has, but declared differently.
+	DriftMismatched = "mismatched"
+
+	// EntityExchange is the `Drift` kind of an exchange.
+	EntityExchange = "exchange"
+	// EntityQueue is the `Drift` kind of a queue.
+	EntityQueue = "queue"
+	// EntityBinding is the `Drift` kind of a binding of a queue or exchange to an exchange.
+	EntityBinding = "binding"
+	// EntityPolicy is the `Drift` kind of a broker policy.
+	EntityPolicy = "policy"
+
+	// managementTimeout bounds the time spent on a single request to the management API.
+	managementTimeout = 10 * time.Second
+)
+
+// Drift is a difference between the topology this service expects & the broker's actual topology.
+type Drift struct {
+	Kind   string `json:"kind"`  // One of the `Entity*` kinds.
+	Name   string `json:"name"`  // The name of the entity. Bindings are named `exchange -> destination (routing.key)`.
+	Issue  string `json:"issue"` // One of the `Drift*` issues.
+	Detail string `json:"detail,omitempty"`
+}
+
+// verification is the outcome of a single topology verification.
+type verification struct {
+	drift           []Drift
+	bindingsChecked bool   // Whether bindings & declarations were verified. This needs the management API.
+	managementError string // Why they could not be verified through the management API, if they could not.
+	unrouted        int    // The number of unrouted events waiting in the `QueueUnrouted` queue.
+}
+
+// verifier is the connection which topology verifications run on. It is separate from the
+// connection events are published on, so that verifications never hold up publishing.
+type verifier struct {
+	lock       chan struct{} // Serializes verifications, & guards the fields below.
+	connection *amqp.Connection
+	closed     chan *amqp.Error // Receives the error which closed `connection`, if any.
+}
+
+// topologyStatus is the result of the latest topology verification.
+type topologyStatus struct {
+	mu         sync.Mutex
+	verified   bool      // Whether the topology has been verified at all.
+	verifiedAt time.Time // When the topology was last verified.
+	latest     verification
+	err        *core.Error // The error which failed the latest verification, if any.
+}
+
+// VerifyTopology will verify the broker's topology against the topology which `EnsureTopology`
+// declares, without changing it, and will return the differences found.
+//
+// Each exchange & queue is declared passively to check that it exists. Nothing is ever declared
+// otherwise, so verifying never changes the topology. The types & arguments of exchanges & queues,
+// and their bindings, can not be inspected over AMQP without declaring them, and are only verified
+// if `BROKER_MANAGEMENT_URL` is set. So is the policy which gives the `events` exchange its
+// alternate exchange, if unrouted events are kept.
+//
+// Verifications run on a connection of their own, without the broker's lock, so that they never
+// hold up publishing.
+//
+// The result is kept for readiness reporting. See `TopologyReadiness`.
+func (broker *Broker) VerifyTopology(ctx context.Context) ([]Drift, *core.Error) {
+	call, err := broker.circuit.allow()
+	if err != nil {
+		return nil, err
+	}
+	result, err := broker.verifyTopology(ctx)
+	broker.circuit.record(call, err)
+
+	broker.topology.mu.Lock()
+	defer broker.topology.mu.Unlock()
+	broker.topology.verified = true
+	broker.topology.verifiedAt = time.Now()
+	broker.topology.latest = result
+	broker.topology.err = err
+	metrics.TopologyDrift.Set(int64(len(result.drift)))
+	if err == nil {
+		metrics.UnroutedEvents.Set(int64(result.unrouted))
+	}
+	return result.drift, err
+}
+
+// TopologyReadiness will report whether the latest topology verification found the broker's
+// topology as expected. It is not ready until the topology has been verified.
+func (broker *Broker) TopologyReadiness() (bool, map[string]interface{}) {
+	broker.topology.mu.Lock()
+	defer broker.topology.mu.Unlock()
+
+	status := &broker.topology
+	details := map[string]interface{}{"verified": status.verified}
+	if !status.verified {
+		return false, details
+	}
+	details["verifiedAt"] = status.verifiedAt
+	if status.err != nil {
+		details["error"] = status.err.GetCode()
+		return false, details
+	}
+	details["bindingsChecked"] = status.latest.bindingsChecked
+	if status.latest.managementError != "" {
+		details["managementError"] = status.latest.managementError
+	}
+	details["unroutedEvents"] = status.latest.unrouted
+	if len(status.latest.drift) > 0 {
+		details["drift"] = status.latest.drift
+	}
+	return len(status.latest.drift) == 0, details
+}
+
+///////////////////////
+// Private Interface //
+
+// verifyTopology is the implementation of `VerifyTopology`.
+func (broker *Broker) verifyTopology(ctx context.Context) (verification, *core.Error) {
+	result := verification{drift: []Drift{}}
+	select {
+	case broker.verifier.lock <- struct{}{}:
+		defer func() { <-broker.verifier.lock }()
+	case <-ctx.Done():
+		return result, core.NewErrorFromContext(ctx.Err())
+	}
+
+	broker.log.Debug("Verifying broker topology.")
+	conn, connErr := broker.verificationConnection(ctx)
+	if connErr != nil {
+		return result, broker.handleVerificationError(ctx, connErr)
+	}
+
+	// Verify that the exchanges & queues exist.
+	for _, exchange := range broker.exchanges {
+		exchange := exchange
+		exchangeDrift, err := checkExists(conn, EntityExchange, exchange.name, func(chn *amqp.Channel) error {
+			return chn.ExchangeDeclarePassive(exchange.name, exchange.kind, true, false, false, false, nil)
+		})
+		if err != nil {
+			return result, broker.handleVerificationError(ctx, err)
+		}
+		if exchangeDrift != nil {
+			result.drift = append(result.drift, *exchangeDrift)
+		}
+	}
+	for _, queue := range broker.queues {
+		queue := queue
+		queueDrift, err := checkExists(conn, EntityQueue, queue.name, func(chn *amqp.Channel) error {
+			_, err := chn.QueueDeclarePassive(queue.name, true, false, false, false, nil)
+			return err
+		})
+		if err != nil {
+			return result, broker.handleVerificationError(ctx, err)
+		}
+		if queueDrift != nil {
+			result.drift = append(result.drift, *queueDrift)
+			continue
+		}
+
+		// Count the unrouted events waiting for inspection.
+		if queue.name == QueueUnrouted {
+			err := withChannel(conn, func(chn *amqp.Channel) error {
+				state, err := chn.QueueDeclarePassive(QueueUnrouted, true, false, false, false, nil)
+				result.unrouted = state.Messages
+				return err
+			})
+			if err != nil {
+				return result, broker.handleVerificationError(ctx, err)
+			}
+			if result.unrouted > 0 {
+				broker.log.WithField("queue", QueueUnrouted).Warnf("%d unrouted events are waiting for inspection.", result.unrouted)
+			}
+		}
+	}
+
+	// Verify the declarations, bindings & policies, if the management API is available. The management API being
+	// unavailable says nothing about the topology, so it only leaves them unchecked.
+	if broker.config.BrokerManagementURL != "" {
+		managementDrift, err := broker.checkManagement(ctx)
+		if err != nil {
+			broker.log.Warnf("Could not verify broker topology through the management API: %s", err.Error())
+			result.managementError = err.Error()
+		} else {
+			result.bindingsChecked = true
+			result.drift = append(result.drift, managementDrift...)
+		}
+	}
+
+	for _, entry := range result.drift {
+		broker.log.WithFields(logrus.Fields{"kind": entry.Kind, "name": entry.Name, "issue": entry.Issue}).Warnf("Broker topology drift: %s", entry.Detail)
+	}
+	if len(result.drift) == 0 {
+		broker.log.Debug("Broker topology is as expected.")
+	}
+	return result, nil
+}
+
+// checkExists will check that an entity of the given kind & name exists, by declaring it passively.
+// The broker closes the channel of a failed declaration, so it runs on a channel of its own. Errors
+// which are not about the entity are returned as is.
+func checkExists(conn *amqp.Connection, kind, name string, passive func(*amqp.Channel) error) (*Drift, error) {
+	if err := withChannel(conn, passive); err != nil {
+		if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.NotFound {
+			return &Drift{Kind: kind, Name: name, Issue: DriftMissing, Detail: amqpErr.Reason}, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// withChannel will call the given func with a new channel of the given connection, closing it afterwards.
+func withChannel(conn *amqp.Connection, fn func(*amqp.Channel) error) error {
+	chn, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer chn.Close()
+	return fn(chn)
+}
+
+// handleChannelError will build an error which can be used in this service from an error of a
+// channel opened with `withChannel`.
+//
+// Unlike `handleError`, the receiver is only reset if the connection was lost, as errors such as a
+// refused declaration close the channel they occurred on, but leave the connection usable.
+func (broker *Broker) handleChannelError(ctx context.Context, err error) *core.Error {
+	if ctxErr := ctx.Err(); ctxErr != nil && err == ctxErr {
+		return core.NewErrorFromContext(ctxErr)
+	}
+	if broker.connectionLost() {
+		broker.reset()
+	}
+	return core.NewFromError(err, broker.log)
+}
+
+// verificationConnection will ensure a live connection for topology verifications exists, and will
+// return it. The caller must hold the verifier's lock.
+//
+// Each configured node is dialed in turn until a connection is established. Dialing is aborted if
+// the given context is done first.
+func (broker *Broker) verificationConnection(ctx context.Context) (*amqp.Connection, error) {
+	verifier := &broker.verifier
+	verifier.dropIfLost()
+	if verifier.connection != nil {
+		return verifier.connection, nil
+	}
+
+	var dialErr error
+	for _, node := range broker.nodes {
+		conn, err := amqp.DialConfig(node.url, amqp.Config{Heartbeat: heartbeat, Locale: "en_US", Dial: dialContext(ctx)})
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			broker.log.WithField("node", node.name).Warnf("Failed to dial broker node for topology verification: %T: %s", err, err.Error())
+			dialErr = err
+			continue
+		}
+		verifier.connection = conn
+		verifier.closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+		return conn, nil
+	}
+	return nil, dialErr
+}
+
+// handleVerificationError will build an error which can be used in this service from an error of
+// a topology verification. The verification connection is dropped if it was lost, so that the next
+// verification dials a new one. The connection events are published on is left alone.
+func (broker *Broker) handleVerificationError(ctx context.Context, err error) *core.Error {
+	if ctxErr := ctx.Err(); ctxErr != nil && err == ctxErr {
+		return core.NewErrorFromContext(ctxErr)
+	}
+	broker.verifier.dropIfLost()
+	return core.NewFromError(err, broker.log)
+}
+
+// dropIfLost will drop the receiver's connection if it has been closed by the broker or the network.
+func (verifier *verifier) dropIfLost() {
+	if verifier.connection == nil {
+		return
+	}
+	select {
+	case <-verifier.closed:
+		verifier.connection.Close()
+		verifier.connection, verifier.closed = nil, nil
+	default:
+	}
+}
+
+// managementBinding is a binding as listed by the management API.
+type managementBinding struct {
+	Destination     string                 `json:"destination"`
+	DestinationType string                 `json:"destination_type"`
+	RoutingKey      string                 `json:"routing_key"`
+	Arguments       map[string]interface{} `json:"arguments"`
+}
+
+// checkManagement will check the declarations & bindings of the topology, & the alternate exchange
+// policy if unrouted events are kept, through the management API.
+func (broker *Broker) checkManagement(ctx context.Context) ([]Drift, error) {
+	drift, err := broker.checkDeclarations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bindingDrift, err := broker.checkBindings(ctx)
+	if err != nil || !broker.config.UnroutedEvents {
+		return append(drift, bindingDrift...), err
+	}
+	drift = append(drift, bindingDrift...)
+	policyDrift, err := broker.checkUnroutedPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policyDrift != nil {
+		drift = append(drift, *policyDrift)
+	}
+	return drift, nil
+}
+
+// checkDeclarations will check that the management API lists every exchange & queue of the topology
+// with its expected type & arguments. Missing entities are left to the passive declarations.
+func (broker *Broker) checkDeclarations(ctx context.Context) ([]Drift, error) {
+	var drift []Drift
+	for _, exchange := range broker.exchanges {
+		var actual managementExchange
+		found, err := broker.managementRequest(ctx, http.MethodGet, "exchanges", exchange.name, nil, &actual)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if actual.Type != exchange.kind || argumentsKey(actual.Arguments) != argumentsKey(nil) {
+			drift = append(drift, Drift{
+				Kind:   EntityExchange,
+				Name:   exchange.name,
+				Issue:  DriftMismatched,
+				Detail: fmt.Sprintf("exchange '%s' is declared as '%s' with arguments %s, expected '%s' with arguments %s", exchange.name, actual.Type, argumentsKey(actual.Arguments), exchange.kind, argumentsKey(nil)),
+			})
+		}
+	}
+	for _, queue := range broker.queues {
+		var actual managementQueue
+		found, err := broker.managementRequest(ctx, http.MethodGet, "queues", queue.name, nil, &actual)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		// Classic is the default queue type, which the broker may list whether it was declared or not.
+		if actual.Arguments["x-queue-type"] == config.QueueClassic {
+			delete(actual.Arguments, "x-queue-type")
+		}
+		if expected := queue.arguments(); argumentsKey(actual.Arguments) != argumentsKey(expected) {
+			drift = append(drift, Drift{
+				Kind:   EntityQueue,
+				Name:   queue.name,
+				Issue:  DriftMismatched,
+				Detail: fmt.Sprintf("queue '%s' is declared with arguments %s, expected %s", queue.name, argumentsKey(actual.Arguments), argumentsKey(expected)),
+			})
+		}
+	}
+	return drift, nil
+}
+
+// argumentsKey will describe the given declaration arguments as JSON, sorted by argument, so that
+// the arguments listed by the management API compare equal to those declared.
+func argumentsKey(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	key, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Sprint(args)
+	}
+	return string(key)
+}
+
+// checkBindings will check that the management API lists every expected binding of the topology.
+func (broker *Broker) checkBindings(ctx context.Context) ([]Drift, error) {
+	actual := map[string]bool{}
+	for _, exchange := range broker.exchanges {
+		bindings, err := broker.listBindings(ctx, exchange.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, binding := range bindings {
+			key := binding.RoutingKey
+			if exchange.kind == exchangeTypeHeaders {
+				key = matchingKey(binding.Arguments)
+			}
+			actual[binding.DestinationType+" "+exchange.name+" "+binding.Destination+" "+key] = true
+		}
+	}
+
+	var drift []Drift
+	for _, binding := range broker.exchangeBindings {
+		if !actual["exchange "+binding.source+" "+binding.destination+" "+binding.routingKey] {
+			drift = append(drift, Drift{
+				Kind:   EntityBinding,
+				Name:   binding.name(),
+				Issue:  DriftMissing,
+				Detail: fmt.Sprintf("exchange '%s' is not bound to exchange '%s' with routing key '%s'", binding.destination, binding.source, binding.routingKey),
+			})
+		}
+	}
+	for _, queue := range broker.queues {
+		if !actual["queue "+queue.source()+" "+queue.name+" "+queue.bindingKey()] {
+			drift = append(drift, Drift{
+				Kind:   EntityBinding,
+				Name:   queue.bindingName(),
+				Issue:  DriftMissing,
+				Detail: fmt.Sprintf("queue '%s' is not bound to exchange '%s' with '%s'", queue.name, queue.source(), queue.bindingKey()),
+			})
+		}
+	}
+	return drift, nil
+}
+
+// managementExchange is an exchange as listed by the management API.
+type managementExchange struct {
+	Type      string                 `json:"type"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Policy    string                 `json:"policy"` // The name of the policy which applies to the exchange, if any.
+}
+
+// managementQueue is a queue as listed by the management API.
+type managementQueue struct {
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// managementPolicy is a policy as set through the management API.
+type managementPolicy struct {
+	Pattern    string                 `json:"pattern"`
+	ApplyTo    string                 `json:"apply-to"`
+	Definition map[string]interface{} `json:"definition"`
+	Priority   int                    `json:"priority"`
+}
+
+// applyUnroutedPolicy will set the policy which gives the `events` exchange its alternate exchange
+// through the management API, with the configured priority. Setting it again is a no-op.
+func (broker *Broker) applyUnroutedPolicy(ctx context.Context) error {
+	policy := managementPolicy{
+		Pattern:    "^" + regexp.QuoteMeta(ExchangeEvents) + "$",
+		ApplyTo:    "exchanges",
+		Definition: map[string]interface{}{"alternate-exchange": ExchangeUnrouted},
+		Priority:   broker.config.UnroutedPolicyPriority,
+	}
+	found, err := broker.managementRequest(ctx, http.MethodPut, "policies", PolicyUnrouted, policy, nil)
+	if err == nil && !found {
+		err = fmt.Errorf("management API does not know the virtual host")
+	}
+	return err
+}
+
+// checkUnroutedPolicy will check that the policy which gives the `events` exchange its alternate
+// exchange applies to it, according to the management API.
+//
+// A policy which is shadowed by another policy of a higher priority is reported as mismatched
+// rather than missing, as setting it again would not make it apply. Its priority needs to be raised
+// instead. See `config.UnroutedPolicyPriority`.
+func (broker *Broker) checkUnroutedPolicy(ctx context.Context) (*Drift, error) {
+	var exchange managementExchange
+	found, err := broker.managementRequest(ctx, http.MethodGet, "exchanges", ExchangeEvents, nil, &exchange)
+	if err != nil || !found || exchange.Policy == PolicyUnrouted {
+		return nil, err
+	}
+
+	if exchange.Policy != "" {
+		return &Drift{
+			Kind:   EntityPolicy,
+			Name:   PolicyUnrouted,
+			Issue:  DriftMismatched,
+			Detail: fmt.Sprintf("policy '%s' applies to exchange '%s' instead, as its priority is higher than %d", exchange.Policy, ExchangeEvents, broker.config.UnroutedPolicyPriority),
+		}, nil
+	}
+	detail := fmt.Sprintf("no policy applies to exchange '%s'", ExchangeEvents)
+	return &Drift{Kind: EntityPolicy, Name: PolicyUnrouted, Issue: DriftMissing, Detail: detail}, nil
+}
+
+// listBindings will list the bindings whose source is the given exchange through the management
+// API. A missing exchange has no bindings.
+func (broker *Broker) listBindings(ctx context.Context, exchange string) ([]managementBinding, error) {
+	var bindings []managementBinding
+	if _, err := broker.managementRequest(ctx, http.MethodGet, "exchanges", exchange+"/bindings/source", nil, &bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// managementRequest will send a request for the given resource of the current virtual host to the
+// management API, e.g. `exchanges` & `events`. The given body is sent as JSON, if any, and the
+// response is decoded into the given value, if any. It will return whether the resource exists.
+func (broker *Broker) managementRequest(ctx context.Context, method, collection, resource string, body, out interface{}) (bool, error) {
+	// Every node of the cluster serves the same virtual host.
+	uri, err := amqp.ParseURI(broker.nodes[0].url)
+	if err != nil {
+		return false, err
+	}
+	segments := strings.Split(resource, "/")
+	segments[0] = url.PathEscape(segments[0])
+	endpoint := fmt.Sprintf("%s/api/%s/%s/%s",
+		strings.TrimSuffix(broker.config.BrokerManagementURL, "/"), collection, url.PathEscape(uri.Vhost), strings.Join(segments, "/"))
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return false, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, managementTimeout)
+	defer cancel()
+	req, err := http.NewRequest(method, endpoint, &reqBody)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return false, fmt.Errorf("management API responded with status %d", resp.StatusCode)
+	case out == nil:
+		return true, nil
+	}
+	return true, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,237 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+// CloudEventsSource is the CloudEvents `source` of all events published by this service.
+const CloudEventsSource = "/mq-service"
+
+// encodingFor will return the encoding of events published with the given routing key.
+func (broker *Broker) encodingFor(routingKey string) string {
+	return encodingFor(broker.config, routingKey)
+}
+
+// encodingFor will return the encoding of events published with the given routing key, according to the given config.
+func encodingFor(cfg *config.Config, routingKey string) string {
+	if encoding, ok := cfg.EventEncodingOverrides[routingKey]; ok {
+		return encoding
+	}
+	return cfg.EventEncoding
+}
+
+// contentTypeFor will return the content type of events published with the given encoding.
+func contentTypeFor(encoding string) string {
+	switch encoding {
+	case config.EncodingJSON:
+		return mq.ContentTypeJSON
+	case config.EncodingCloudEventsStructured:
+		return mq.ContentTypeCloudEventsJSON
+	default:
+		return mq.ContentTypeProtobuf
+	}
+}
+
+// encodeEvent will encode the given event into the body, content type & headers of the given publishing.
+//
+// The publishing's `Type` must already be set. The CloudEvents `id` & `time` attributes are the
+// event's ID & occurred-at time, and its remaining metadata is carried as extension attributes.
+// Events are decoded with `mq.DecodeDelivery`.
+func encodeEvent(encoding string, event *mq.SystemEvent, msg *amqp.Publishing) error {
+	message, ok := event.GetEvent().(mq.SystemEventMessage)
+	if !ok {
+		return fmt.Errorf("event has no event message: %T", event.GetEvent())
+	}
+
+	switch encoding {
+	case config.EncodingJSON:
+		body, err := marshalJSON(event)
+		if err != nil {
+			return err
+		}
+		msg.ContentType, msg.Body = mq.ContentTypeJSON, body
+
+	case config.EncodingCloudEventsStructured:
+		data, err := marshalJSON(mq.Payload(message))
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(mq.CloudEvent{
+			SpecVersion:     mq.CloudEventsSpecVersion,
+			ID:              event.GetId(),
+			Source:          CloudEventsSource,
+			Type:            msg.Type,
+			Time:            ptypes.TimestampString(event.GetOccurredAt()),
+			DataContentType: mq.ContentTypeJSON,
+			RequestID:       event.GetContext().GetRequestid(),
+			CorrelationID:   event.GetCorrelationId(),
+			CausationID:     event.GetCausationId(),
+			Producer:        event.GetProducer(),
+			Data:            data,
+		})
+		if err != nil {
+			return err
+		}
+		msg.ContentType, msg.Body = mq.ContentTypeCloudEventsJSON, body
+
+	case config.EncodingCloudEventsBinary:
+		body, err := proto.Marshal(mq.Payload(message))
+		if err != nil {
+			return err
+		}
+		setHeader(msg, mq.CloudEventsHeaderPrefix+"specversion", mq.CloudEventsSpecVersion)
+		setHeader(msg, mq.CloudEventsHeaderPrefix+"id", event.GetId())
+		setHeader(msg, mq.CloudEventsHeaderPrefix+"source", CloudEventsSource)
+		setHeader(msg, mq.CloudEventsHeaderPrefix+"type", msg.Type)
+		setHeader(msg, mq.CloudEventsHeaderPrefix+"time", ptypes.TimestampString(event.GetOccurredAt()))
+		extensions := map[string]string{
+			"requestid":     event.GetContext().GetRequestid(),
+			"correlationid": event.GetCorrelationId(),
+			"causationid":   event.GetCausationId(),
+			"producer":      event.GetProducer(),
+		}
+		for name, value := range extensions {
+			if value != "" {
+				setHeader(msg, mq.CloudEventsHeaderPrefix+name, value)
+			}
+		}
+		msg.ContentType, msg.Body = mq.ContentTypeProtobuf, body
+
+	default:
+		body, err := proto.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msg.ContentType, msg.Body = mq.ContentTypeProtobuf, body
+	}
+
+	return nil
+}
+
+// marshalJSON will marshal the given protobuf message to protobuf-JSON.
+func marshalJSON(message proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := new(jsonpb.Marshaler).Marshal(&buf, message); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressBody will compress the body of the given publishing, if compression is enabled and the
+// body is at least as large as the configured threshold.
+func (broker *Broker) compressBody(msg *amqp.Publishing) error {
+	encoding := broker.config.EventCompression
+	if encoding == "" || len(msg.Body) < broker.config.EventCompressionThreshold {
+		return nil
+	}
+
+	body, err := mq.Compress(encoding, msg.Body)
+	if err != nil {
+		return err
+	}
+	msg.ContentEncoding, msg.Body = encoding, body
+	return nil
+}
+
+// routingHeaders will return the configured routing headers of the given event, for routing by
+// headers exchanges. See `config.RoutingHeaders`.
+//
+// Header values are read from the protobuf-JSON encodings of the event message & the request
+// context, and are set as strings. Fields which are missing or not scalar are left out.
+func (broker *Broker) routingHeaders(message mq.SystemEventMessage, reqCtx *core.Context) (amqp.Table, error) {
+	headers := amqp.Table{}
+	if len(broker.config.RoutingHeaders) == 0 {
+		return headers, nil
+	}
+	if reqCtx == nil {
+		reqCtx = &core.Context{}
+	}
+	eventFields, err := jsonFields(mq.Payload(message))
+	if err != nil {
+		return nil, err
+	}
+	contextFields, err := jsonFields(reqCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	for header, source := range broker.config.RoutingHeaders {
+		var value interface{}
+		var ok bool
+		if strings.HasPrefix(source, config.RoutingHeaderEvent) {
+			value, ok = lookupField(eventFields, strings.TrimPrefix(source, config.RoutingHeaderEvent))
+		} else {
+			value, ok = lookupField(contextFields, strings.TrimPrefix(source, config.RoutingHeaderContext))
+		}
+		if ok {
+			headers[header] = fmt.Sprint(value)
+		}
+	}
+	return headers, nil
+}
+
+// jsonFields will return the fields of the protobuf-JSON encoding of the given message, including
+// those with default values. Numbers are kept as `json.Number`s, so that they print as encoded.
+func jsonFields(message proto.Message) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{EmitDefaults: true}).Marshal(&buf, message); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// lookupField will look up the scalar value at the given dotted path of the given fields.
+func lookupField(fields map[string]interface{}, path string) (interface{}, bool) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		nested, ok := fields[name].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		fields = nested
+	}
+	switch value := fields[names[len(names)-1]].(type) {
+	case string, json.Number, bool:
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// setHeader will set the given header on the given publishing.
+func setHeader(msg *amqp.Publishing, key string, value interface{}) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Headers[key] = value
+}
+
+// newEventID will generate a new random (version 4) UUID for identifying an event.
+func newEventID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err) // The system's source of randomness is broken. Nothing sensible can be done.
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
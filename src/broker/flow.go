@@ -0,0 +1,151 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+// flowControl tracks whether the broker has blocked the current connection.
+//
+// RabbitMQ blocks connections which publish while one of its resource alarms is active. While
+// blocked, the broker stops reading from the connection, so publishings would hang.
+type flowControl struct {
+	mu        sync.Mutex
+	conn      *amqp.Connection // The connection whose state is tracked.
+	blocked   bool
+	reason    string
+	since     time.Time
+	unblocked chan struct{} // Closed once the connection is unblocked.
+	blocking  chan struct{} // Closed once the connection is blocked.
+}
+
+// flowState is a snapshot of a `flowControl`'s state.
+type flowState struct {
+	blocked   bool
+	reason    string
+	since     time.Time
+	unblocked <-chan struct{}
+	blocking  <-chan struct{}
+}
+
+// newFlowControl will build and return a new `flowControl` instance.
+func newFlowControl() *flowControl {
+	return &flowControl{unblocked: make(chan struct{}), blocking: make(chan struct{})}
+}
+
+// attach will start tracking the given connection. New connections start out unblocked.
+func (flow *flowControl) attach(conn *amqp.Connection) {
+	flow.mu.Lock()
+	defer flow.mu.Unlock()
+
+	flow.conn = conn
+	flow.setUnblocked()
+}
+
+// update will apply the given notification for the given connection, reporting whether the state changed.
+//
+// Notifications for connections other than the tracked one are ignored.
+func (flow *flowControl) update(conn *amqp.Connection, blocking amqp.Blocking) bool {
+	flow.mu.Lock()
+	defer flow.mu.Unlock()
+
+	if conn != flow.conn || blocking.Active == flow.blocked {
+		return false
+	}
+	if !blocking.Active {
+		flow.setUnblocked()
+		return true
+	}
+
+	flow.blocked = true
+	flow.reason = blocking.Reason
+	flow.since = time.Now()
+	flow.unblocked = make(chan struct{})
+	close(flow.blocking)
+	return true
+}
+
+// state will return a snapshot of the receiver's state.
+func (flow *flowControl) state() flowState {
+	flow.mu.Lock()
+	defer flow.mu.Unlock()
+
+	return flowState{flow.blocked, flow.reason, flow.since, flow.unblocked, flow.blocking}
+}
+
+// setUnblocked will mark the receiver as unblocked. The caller must hold the receiver's lock.
+func (flow *flowControl) setUnblocked() {
+	if flow.blocked {
+		close(flow.unblocked)
+		flow.blocking = make(chan struct{})
+	}
+	flow.blocked = false
+	flow.reason = ""
+	flow.since = time.Time{}
+}
+
+// watchBlocked will track the blocked state of the given connection until it is closed.
+//
+// This routine is meant to be run in its own goroutine.
+func (broker *Broker) watchBlocked(node node, conn *amqp.Connection, blockings chan amqp.Blocking) {
+	for blocking := range blockings {
+		if !broker.flow.update(conn, blocking) {
+			continue
+		}
+
+		log := broker.log.WithField("node", node.name)
+		if blocking.Active {
+			log.WithField("reason", blocking.Reason).Warn("Broker has blocked the connection. Publishing is paused.")
+			metrics.BrokerBlocked.Set(1)
+			metrics.BrokerBlockedTotal.Add(1)
+		} else {
+			log.Info("Broker has unblocked the connection. Publishing is resumed.")
+			metrics.BrokerBlocked.Set(0)
+		}
+	}
+}
+
+// awaitUnblocked will ensure that the broker has not blocked the current connection.
+//
+// If it has, then depending on the configured policy this routine will either fail right away, or
+// wait up to the configured timeout for the connection to be unblocked.
+func (broker *Broker) awaitUnblocked(ctx context.Context) *core.Error {
+	state := broker.flow.state()
+	if !state.blocked {
+		return nil
+	}
+
+	if broker.config.BrokerBlockedPolicy == config.BlockedWait {
+		timer := time.NewTimer(broker.config.BrokerBlockedTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-state.unblocked:
+			return nil
+		case <-ctx.Done():
+			return core.NewErrorFromContext(ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	broker.log.WithFields(logrus.Fields{
+		"reason":       state.reason,
+		"blockedSince": state.since,
+	}).Warn("Rejecting publishing while the broker has blocked the connection.")
+	return newBlockedError(state.reason)
+}
+
+// newBlockedError will build a `BROKER_BLOCKED` error for the given reason of the broker.
+func newBlockedError(reason string) *core.Error {
+	err := core.NewError(core.CodeBrokerBlocked)
+	err.Meta["reason"] = reason
+	return err
+}
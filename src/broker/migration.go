@@ -0,0 +1,492 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+const (
+	// QueueMigrations is the queue which a record of every applied topology migration is published to.
+	QueueMigrations = "topology.migrations"
+
+	// holdingQueueSuffix is appended to the name of a migrated queue to name the queue which holds
+	// its messages while it is redeclared.
+	holdingQueueSuffix = ".migrating"
+)
+
+// MigrationPlan is the set of migrations which bring the broker's topology to the expected topology.
+type MigrationPlan struct {
+	Migrations  []QueueMigration `json:"migrations"`
+	Unsupported []Drift          `json:"unsupported,omitempty"` // Drift which can not be migrated, and needs manual action.
+}
+
+// QueueMigration is the migration of a queue which is declared with other arguments than expected.
+//
+// The queue's messages are moved to a holding queue while the queue is deleted & redeclared with
+// the expected arguments, and are then moved back. The holding queue is bound to the queue's
+// routing key before the queue is unbound, and unbound only after the redeclared queue is bound,
+// so that no events published meanwhile are lost. Events published while both queues are bound
+// are routed to both, and so are delivered twice once moved back; consumers should deduplicate
+// events by their ID.
+//
+// Queues with consumers are not migrated, as consumers would take messages out of the queue while
+// they are moved, and the queue is only deleted while unused.
+type QueueMigration struct {
+	Queue      string     `json:"queue"`
+	RoutingKey string     `json:"routingKey"`
+	Arguments  amqp.Table `json:"arguments"` // The arguments the queue is redeclared with.
+	Reason     string     `json:"reason"`    // Why the queue needs migrating, as reported by the broker.
+	Messages   int        `json:"messages"`  // The number of messages in the queue when planned.
+	Consumers  int        `json:"consumers"` // The number of consumers of the queue when planned.
+	Steps      []string   `json:"steps"`     // A description of each step of the migration.
+}
+
+// MigrationRecord is the record of an applied `QueueMigration`, as published to `QueueMigrations`.
+type MigrationRecord struct {
+	Queue         string     `json:"queue"`
+	Arguments     amqp.Table `json:"arguments"`
+	Reason        string     `json:"reason"`
+	StartedAt     time.Time  `json:"startedAt"`
+	FinishedAt    time.Time  `json:"finishedAt"`
+	StepsApplied  int        `json:"stepsApplied"`
+	MessagesMoved int        `json:"messagesMoved"`
+	Error         string     `json:"error,omitempty"`      // Why the migration failed, if it did.
+	RolledBack    bool       `json:"rolledBack,omitempty"` // Whether the steps of a failed migration were undone.
+}
+
+// PlanMigrations will verify the broker's topology & plan the migrations which bring it to the
+// expected topology, without changing it.
+//
+// Only queues declared with other arguments than expected are migrated. Missing entities are
+// declared by `EnsureTopology` instead, and any other drift is reported as unsupported, as are
+// queues which can not be migrated safely: streams, queues with a length limit, which could drop
+// or reject moved messages, and queues with consumers.
+//
+// The arguments of queues can only be compared through the management API, so planning needs
+// `BROKER_MANAGEMENT_URL`, and fails if the management API can not be reached.
+func (broker *Broker) PlanMigrations(ctx context.Context) (*MigrationPlan, *core.Error) {
+	if broker.config.BrokerManagementURL == "" {
+		return nil, core.NewInvalidArgument("brokerManagementURL", "Migrations need the broker's management API.")
+	}
+	drift, err := broker.VerifyTopology(ctx)
+	if err != nil {
+		return nil, err
+	}
+	broker.topology.mu.Lock()
+	managementError := broker.topology.latest.managementError
+	broker.topology.mu.Unlock()
+	if managementError != "" {
+		return nil, core.NewFromError(fmt.Errorf("could not verify queue arguments: %s", managementError), broker.log)
+	}
+
+	queues := map[string]queueDeclaration{}
+	for _, queue := range broker.queues {
+		queues[queue.name] = queue
+	}
+
+	plan := &MigrationPlan{Migrations: []QueueMigration{}}
+	for _, entry := range drift {
+		queue, ok := queues[entry.Name]
+		switch {
+		case entry.Issue == DriftMissing:
+			continue
+		case entry.Kind != EntityQueue || !ok:
+			plan.Unsupported = append(plan.Unsupported, entry)
+			continue
+		}
+		if detail := queue.unmigratable(); detail != "" {
+			entry.Detail = fmt.Sprintf("%s; %s", entry.Detail, detail)
+			plan.Unsupported = append(plan.Unsupported, entry)
+			continue
+		}
+
+		migration := QueueMigration{
+			Queue:      queue.name,
+			RoutingKey: queue.routingKey,
+			Arguments:  queue.arguments(),
+			Reason:     entry.Detail,
+		}
+		for _, step := range migrationSteps(queue, nil) {
+			migration.Steps = append(migration.Steps, step.description)
+		}
+		plan.Migrations = append(plan.Migrations, migration)
+	}
+
+	if len(plan.Migrations) > 0 {
+		if err := broker.countMessages(ctx, plan.Migrations); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := plan.Migrations[:0]
+	for _, migration := range plan.Migrations {
+		if migration.Consumers == 0 {
+			migrations = append(migrations, migration)
+			continue
+		}
+		plan.Unsupported = append(plan.Unsupported, Drift{
+			Kind:   EntityQueue,
+			Name:   migration.Queue,
+			Issue:  DriftMismatched,
+			Detail: fmt.Sprintf("%s; the queue has %d consumers, which must be stopped before it is migrated", migration.Reason, migration.Consumers),
+		})
+	}
+	plan.Migrations = migrations
+	return plan, nil
+}
+
+// ApplyMigrations will apply the migrations of the given plan, in order, and will return a record
+// of each migration attempted. Each record is also published to the `QueueMigrations` queue.
+//
+// Applying stops at the first migration which fails. A migration which fails before its queue is
+// deleted is rolled back: the queue is bound again, and the holding queue's messages are moved back
+// to it. One which fails later leaves the holding queue bound, so that no events are lost, and
+// must be finished by hand. The failed migration's record tells which step failed.
+func (broker *Broker) ApplyMigrations(ctx context.Context, plan *MigrationPlan) ([]MigrationRecord, *core.Error) {
+	call, err := broker.circuit.allow()
+	if err != nil {
+		return nil, err
+	}
+	records, err := broker.applyMigrations(ctx, plan)
+	broker.circuit.record(call, err)
+	return records, err
+}
+
+///////////////////////
+// Private Interface //
+
+// migrationStep is a single step of a `QueueMigration`.
+type migrationStep struct {
+	description  string
+	run          func(chn *migrationChannel) error
+	undo         func(chn *migrationChannel) error // Undoes the step, if it needs undoing when rolling back.
+	irreversible bool                              // Whether the migration can not be rolled back once the step is applied.
+}
+
+// migrationChannel is the channel which a migration runs on, in confirm mode.
+type migrationChannel struct {
+	*amqp.Channel
+	confirms chan amqp.Confirmation // Receives publisher confirms for the channel.
+	returns  chan amqp.Return       // Receives unroutable publishings returned on the channel.
+}
+
+// migrationSteps will build the steps which migrate the given queue to its expected declaration.
+//
+// Each message moved out of the queue is counted in the given counter, if any.
+func migrationSteps(queue queueDeclaration, moved *int) []migrationStep {
+	holding := queue.name + holdingQueueSuffix
+	return []migrationStep{
+		{
+			description: fmt.Sprintf("declare holding queue '%s' with arguments %v", holding, queue.arguments()),
+			run: func(chn *migrationChannel) error {
+				_, err := chn.QueueDeclare(holding, true, false, false, false, queue.arguments())
+				return err
+			},
+			undo: func(chn *migrationChannel) error {
+				if err := moveMessages(chn, holding, queue.name, nil); err != nil {
+					return err
+				}
+				_, err := chn.QueueDelete(holding, true, true, false)
+				return err
+			},
+		},
+		{
+			description: fmt.Sprintf("bind holding queue '%s' to '%s'", holding, queue.bindingKey()),
+			run: func(chn *migrationChannel) error {
+				return chn.QueueBind(holding, queue.routingKey, queue.source(), false, queue.matching)
+			},
+			undo: func(chn *migrationChannel) error {
+				return chn.QueueUnbind(holding, queue.routingKey, queue.source(), queue.matching)
+			},
+		},
+		{
+			description: fmt.Sprintf("unbind queue '%s' from '%s'", queue.name, queue.bindingKey()),
+			run: func(chn *migrationChannel) error {
+				return chn.QueueUnbind(queue.name, queue.routingKey, queue.source(), queue.matching)
+			},
+			undo: func(chn *migrationChannel) error {
+				return chn.QueueBind(queue.name, queue.routingKey, queue.source(), false, queue.matching)
+			},
+		},
+		{
+			description: fmt.Sprintf("move messages from '%s' to '%s'", queue.name, holding),
+			run: func(chn *migrationChannel) error {
+				return moveMessages(chn, queue.name, holding, moved)
+			},
+		},
+		{
+			description: fmt.Sprintf("delete empty & unused queue '%s'", queue.name),
+			run: func(chn *migrationChannel) error {
+				_, err := chn.QueueDelete(queue.name, true, true, false)
+				return err
+			},
+			irreversible: true,
+		},
+		{
+			description: fmt.Sprintf("declare queue '%s' with arguments %v & bind it to '%s'", queue.name, queue.arguments(), queue.bindingKey()),
+			run: func(chn *migrationChannel) error {
+				return queue.declare(chn.Channel)
+			},
+		},
+		{
+			description: fmt.Sprintf("unbind holding queue '%s' from '%s'", holding, queue.bindingKey()),
+			run: func(chn *migrationChannel) error {
+				return chn.QueueUnbind(holding, queue.routingKey, queue.source(), queue.matching)
+			},
+		},
+		{
+			description: fmt.Sprintf("move messages from '%s' to '%s'", holding, queue.name),
+			run: func(chn *migrationChannel) error {
+				return moveMessages(chn, holding, queue.name, nil)
+			},
+		},
+		{
+			description: fmt.Sprintf("delete empty & unused holding queue '%s'", holding),
+			run: func(chn *migrationChannel) error {
+				_, err := chn.QueueDelete(holding, true, true, false)
+				return err
+			},
+		},
+	}
+}
+
+// countMessages will set the number of messages & consumers currently in the queue of each of the
+// given migrations.
+func (broker *Broker) countMessages(ctx context.Context, migrations []QueueMigration) *core.Error {
+	if err := broker.acquire(ctx); err != nil {
+		return core.NewErrorFromContext(err)
+	}
+	defer broker.release()
+
+	conn, connErr := broker.getConnection(ctx)
+	if connErr != nil {
+		return broker.handleError(ctx, connErr)
+	}
+	for idx := range migrations {
+		migration := &migrations[idx]
+		err := withChannel(conn, func(chn *amqp.Channel) error {
+			queue, err := chn.QueueDeclarePassive(migration.Queue, true, false, false, false, nil)
+			migration.Messages = queue.Messages
+			migration.Consumers = queue.Consumers
+			return err
+		})
+		if err != nil {
+			return broker.handleChannelError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// applyMigrations is the implementation of `ApplyMigrations`.
+func (broker *Broker) applyMigrations(ctx context.Context, plan *MigrationPlan) ([]MigrationRecord, *core.Error) {
+	if err := broker.acquire(ctx); err != nil {
+		return nil, core.NewErrorFromContext(err)
+	}
+	defer broker.release()
+
+	conn, connErr := broker.getConnection(ctx)
+	if connErr != nil {
+		return nil, broker.handleError(ctx, connErr)
+	}
+
+	queues := map[string]queueDeclaration{}
+	for _, queue := range broker.queues {
+		queues[queue.name] = queue
+	}
+
+	var records []MigrationRecord
+	for _, migration := range plan.Migrations {
+		queue, ok := queues[migration.Queue]
+		if !ok {
+			return records, core.NewInvalidArgument("queue", fmt.Sprintf("Queue '%s' is not part of the topology.", migration.Queue))
+		}
+
+		record, err := broker.applyMigration(ctx, conn, queue, migration.Reason)
+		if recordErr := broker.recordMigration(conn, record); recordErr != nil {
+			broker.log.Errorf("Error recording topology migration: %T: %s", recordErr, recordErr.Error())
+		}
+		records = append(records, record)
+		if err != nil {
+			return records, broker.handleChannelError(ctx, err)
+		}
+	}
+	return records, nil
+}
+
+// applyMigration will migrate the given queue, and will return a record of the migration.
+//
+// All steps run on one channel of their own, in confirm mode so that moved messages are confirmed.
+// If a step fails, the applied steps are undone on another channel, unless the migration can no
+// longer be rolled back.
+func (broker *Broker) applyMigration(ctx context.Context, conn *amqp.Connection, queue queueDeclaration, reason string) (MigrationRecord, error) {
+	record := MigrationRecord{Queue: queue.name, Arguments: queue.arguments(), Reason: reason, StartedAt: time.Now()}
+	log := broker.log.WithField("queue", queue.name)
+	log.Infof("Migrating queue: %s", reason)
+
+	steps := migrationSteps(queue, &record.MessagesMoved)
+	reversible := true
+	err := withMigrationChannel(conn, func(chn *migrationChannel) error {
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			log.Infof("Migration step %d: %s.", record.StepsApplied+1, step.description)
+			if err := step.run(chn); err != nil {
+				return err
+			}
+			record.StepsApplied++
+			reversible = reversible && !step.irreversible
+		}
+		return nil
+	})
+
+	if err != nil && reversible {
+		// The failed step's channel may have been closed by the broker, so undo on another one.
+		rollbackErr := withMigrationChannel(conn, func(chn *migrationChannel) error {
+			for idx := record.StepsApplied - 1; idx >= 0; idx-- {
+				if steps[idx].undo == nil {
+					continue
+				}
+				log.Infof("Undoing migration step %d: %s.", idx+1, steps[idx].description)
+				if err := steps[idx].undo(chn); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if rollbackErr != nil {
+			log.Errorf("Error rolling back queue migration: %T: %s", rollbackErr, rollbackErr.Error())
+			err = fmt.Errorf("%s (rolling back failed: %s)", err.Error(), rollbackErr.Error())
+		} else {
+			record.RolledBack = true
+		}
+	}
+
+	record.FinishedAt = time.Now()
+	if err != nil {
+		record.Error = err.Error()
+		log.WithFields(logrus.Fields{"stepsApplied": record.StepsApplied, "rolledBack": record.RolledBack}).Errorf("Queue migration failed: %T: %s", err, err.Error())
+		metrics.TopologyMigrations.Add("failed", 1)
+		return record, err
+	}
+	log.WithFields(logrus.Fields{"messagesMoved": record.MessagesMoved}).Info("Queue migrated.")
+	metrics.TopologyMigrations.Add("applied", 1)
+	return record, nil
+}
+
+// withMigrationChannel will run the given function with a channel of its own in confirm mode,
+// which is closed once the function returns.
+func withMigrationChannel(conn *amqp.Connection, fn func(*migrationChannel) error) error {
+	return withChannel(conn, func(chn *amqp.Channel) error {
+		if err := chn.Confirm(false); err != nil {
+			return err
+		}
+		return fn(&migrationChannel{
+			Channel:  chn,
+			confirms: chn.NotifyPublish(make(chan amqp.Confirmation, 1)),
+			returns:  chn.NotifyReturn(make(chan amqp.Return, 1)),
+		})
+	})
+}
+
+// unmigratable will tell why the queue can not be migrated safely, if it can not.
+//
+// Streams can not be read with `basic.get`, and a length limit could drop or reject messages
+// moved into the holding queue or back.
+func (queue queueDeclaration) unmigratable() string {
+	settings := queue.settings
+	switch {
+	case settings.Type == config.QueueStream:
+		return "streams can not be migrated, and must be migrated by hand"
+	case settings.MaxLength > 0 || settings.MaxBytes > 0:
+		return "queues with a length limit can not be migrated without dropping or rejecting messages, and must be migrated by hand"
+	}
+	return ""
+}
+
+// moveMessages will move every message of one queue to another, through the default exchange.
+//
+// Each message is acknowledged only once the broker has confirmed its publishing to the other
+// queue, so messages are never lost, though a message may be duplicated if moving is interrupted.
+// Each message moved is counted in the given counter, if any.
+func moveMessages(chn *migrationChannel, from, to string, moved *int) error {
+	for {
+		delivery, ok, err := chn.Get(from, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		msg := amqp.Publishing{
+			Headers:         delivery.Headers,
+			ContentType:     delivery.ContentType,
+			ContentEncoding: delivery.ContentEncoding,
+			DeliveryMode:    delivery.DeliveryMode,
+			Priority:        delivery.Priority,
+			CorrelationId:   delivery.CorrelationId,
+			ReplyTo:         delivery.ReplyTo,
+			Expiration:      delivery.Expiration,
+			MessageId:       delivery.MessageId,
+			Timestamp:       delivery.Timestamp,
+			Type:            delivery.Type,
+			UserId:          delivery.UserId,
+			AppId:           delivery.AppId,
+			Body:            delivery.Body,
+		}
+		if err := chn.Publish("", to, true, false, msg); err != nil {
+			return err
+		}
+
+		// The message stays in its queue unless its move is confirmed.
+		confirm, ok := <-chn.confirms
+		if !ok {
+			return amqp.ErrClosed
+		}
+		if !confirm.Ack {
+			return fmt.Errorf("moving message to '%s' was nacked by the broker", to)
+		}
+		select {
+		case ret := <-chn.returns:
+			return fmt.Errorf("moving message to '%s' was returned by the broker: %s", to, ret.ReplyText)
+		default:
+		}
+
+		if err := delivery.Ack(false); err != nil {
+			return err
+		}
+		if moved != nil {
+			*moved++
+		}
+	}
+}
+
+// recordMigration will publish the given record to the `QueueMigrations` queue, declaring it if needed.
+func (broker *Broker) recordMigration(conn *amqp.Connection, record MigrationRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return withChannel(conn, func(chn *amqp.Channel) error {
+		if _, err := chn.QueueDeclare(QueueMigrations, true, false, false, false, nil); err != nil {
+			return err
+		}
+		return chn.Publish("", QueueMigrations, false, false, amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "application/json",
+			Timestamp:    record.FinishedAt,
+			AppId:        "mq-service",
+			Body:         body,
+		})
+	})
+}
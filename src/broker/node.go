@@ -0,0 +1,22 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// node is a single broker node which this service may connect to.
+type node struct {
+	url  string // The node's connection string, including credentials.
+	name string // The node's name, free of credentials, for use in logs & metrics.
+}
+
+// newNode will build a `node` from the given connection string.
+func newNode(url string) node {
+	uri, err := amqp.ParseURI(url)
+	if err != nil {
+		return node{url, "invalid-connection-string"}
+	}
+	return node{url, fmt.Sprintf("%s:%d", uri.Host, uri.Port)}
+}
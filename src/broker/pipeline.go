@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+// Publisher is the interface through which events are published to the broker.
+//
+// It is implemented by `Broker`, which publishes on the caller's goroutine, and by `Pipeline`,
+// which publishes via a bounded queue.
+type Publisher interface {
+	PublishEvent(ctx context.Context, message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions) *core.Error
+}
+
+// Pipeline is an asynchronous publishing pipeline in front of a `Broker`.
+//
+// Events are put onto a bounded queue, which is drained by a fixed number of publisher workers.
+// When the queue is full, events are rejected right away instead of piling up.
+type Pipeline struct {
+	config *config.Config
+	log    *logrus.Logger
+	broker *Broker
+
+	jobs chan *publishJob
+}
+
+// publishJob is a single event waiting in a `Pipeline`'s queue.
+type publishJob struct {
+	ctx     context.Context
+	message mq.SystemEventMessage
+	reqCtx  *core.Context
+	opts    PublishOptions
+	done    chan *core.Error // Receives the outcome of the publishing. Buffered, so workers never block on it.
+}
+
+// NewPipeline will build and return a `Pipeline` instance, starting its publisher workers.
+func NewPipeline(cfg *config.Config, log *logrus.Logger, broker *Broker) *Pipeline {
+	pipeline := &Pipeline{cfg, log, broker, make(chan *publishJob, cfg.PublishQueueSize)}
+
+	log.Infof("Starting %d publisher workers with a queue of %d events.", cfg.PublishWorkers, cfg.PublishQueueSize)
+	for worker := 0; worker < cfg.PublishWorkers; worker++ {
+		go pipeline.work()
+	}
+	return pipeline
+}
+
+// Enqueue will put the given event onto the queue, returning a channel which receives the
+// outcome of the publishing once it is done.
+//
+// Invalid options are rejected with an `INVALID_ARGUMENT` error right away, as are events while
+// the queue is full, with a `RESOURCE_EXHAUSTED` error.
+func (pipeline *Pipeline) Enqueue(ctx context.Context, message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions) (<-chan *core.Error, *core.Error) {
+	if _, err := pipeline.broker.routeEvent(message, reqCtx, opts); err != nil {
+		return nil, err
+	}
+
+	job := &publishJob{ctx, message, reqCtx, opts, make(chan *core.Error, 1)}
+	select {
+	case pipeline.jobs <- job:
+		metrics.PublishQueueDepth.Add(1)
+		return job.done, nil
+	default:
+		pipeline.log.Warn("Publish queue is full. Rejecting event.")
+		metrics.PublishQueueRejections.Add(1)
+		return nil, core.NewError(core.CodeResourceExhausted)
+	}
+}
+
+// PublishEvent will put the given event onto the queue, and will wait for it to be published.
+//
+// If the given context is cancelled or its deadline passes first, the wait is aborted. The
+// event is dropped from the queue once a worker picks it up.
+func (pipeline *Pipeline) PublishEvent(ctx context.Context, message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions) *core.Error {
+	done, err := pipeline.Enqueue(ctx, message, reqCtx, opts)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return core.NewErrorFromContext(ctx.Err())
+	}
+}
+
+///////////////////////
+// Private Interface //
+
+// work will publish events from the queue, one at a time, for as long as the queue is open.
+//
+// This routine is meant to be run in its own goroutine.
+func (pipeline *Pipeline) work() {
+	for job := range pipeline.jobs {
+		metrics.PublishQueueDepth.Add(-1)
+
+		// Don't bother publishing events whose callers have already given up.
+		if err := job.ctx.Err(); err != nil {
+			job.done <- core.NewErrorFromContext(err)
+			continue
+		}
+		job.done <- pipeline.broker.PublishEvent(job.ctx, job.message, job.reqCtx, job.opts)
+	}
+}
@@ -0,0 +1,217 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/metrics"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+// reconcileTimeout bounds the time spent on a single reconciliation.
+const reconcileTimeout = 30 * time.Second
+
+// ReconcileTopology will verify the broker's topology, re-declare any missing exchange, queue or
+// binding, set the alternate exchange policy again if it is missing, and will return the drift it
+// corrected.
+//
+// Entities declared with other settings than expected can not be corrected without losing
+// messages. They are left as they are, and reported by `TopologyReadiness`. See `PlanMigrations`.
+func (broker *Broker) ReconcileTopology(ctx context.Context) ([]Drift, *core.Error) {
+	drift, err := broker.VerifyTopology(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing, entities []Drift
+	for _, entry := range drift {
+		if entry.Issue == DriftMissing {
+			missing = append(missing, entry)
+			if entry.Kind != EntityPolicy {
+				entities = append(entities, entry)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	if len(entities) > 0 {
+		call, err := broker.circuit.allow()
+		if err != nil {
+			return nil, err
+		}
+		err = broker.declareMissing(ctx, entities)
+		broker.circuit.record(call, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The policy is set through the management API, so outside of the broker's lock, once the
+	// alternate exchange it names is in place.
+	for _, entry := range missing {
+		if entry.Kind != EntityPolicy {
+			continue
+		}
+		if err := broker.applyUnroutedPolicy(ctx); err != nil {
+			return nil, core.NewFromError(err, broker.log)
+		}
+		broker.log.WithFields(logrus.Fields{"kind": entry.Kind, "name": entry.Name}).Warn("Re-applied missing broker policy.")
+		metrics.TopologyCorrections.Add(entry.Kind, 1)
+	}
+
+	// Verify again, so that readiness reflects the corrected topology.
+	if _, err := broker.VerifyTopology(ctx); err != nil {
+		return missing, err
+	}
+	return missing, nil
+}
+
+// RunReconciler will reconcile the broker's topology every `BROKER_RECONCILE_INTERVAL`, and after
+// every reconnect to the broker, until the given context is done.
+//
+// This routine blocks, and should be run in its own goroutine.
+func (broker *Broker) RunReconciler(ctx context.Context) {
+	broker.runScheduled(ctx, func(ctx context.Context) {
+		if _, err := broker.ReconcileTopology(ctx); err != nil {
+			broker.log.Errorf("Error while reconciling broker topology: %s", err.Error())
+			metrics.TopologyReconcileFailures.Add(1)
+		}
+	})
+}
+
+// RunVerifier will verify the broker's topology every `BROKER_RECONCILE_INTERVAL`, and after every
+// reconnect to the broker, until the given context is done. Unlike `RunReconciler`, it never
+// changes the topology, so that readiness keeps reporting drift which is left to an operator.
+//
+// This routine blocks, and should be run in its own goroutine.
+func (broker *Broker) RunVerifier(ctx context.Context) {
+	broker.runScheduled(ctx, func(ctx context.Context) {
+		if _, err := broker.VerifyTopology(ctx); err != nil {
+			broker.log.Errorf("Error while verifying broker topology: %s", err.Error())
+		}
+	})
+}
+
+///////////////////////
+// Private Interface //
+
+// runScheduled will call the given func every `BROKER_RECONCILE_INTERVAL`, and after every
+// reconnect to the broker, until the given context is done. Each call is bounded by `reconcileTimeout`.
+func (broker *Broker) runScheduled(ctx context.Context, run func(ctx context.Context)) {
+	var ticks <-chan time.Time
+	if interval := broker.config.BrokerReconcileInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		case <-broker.reconnected:
+			broker.log.Debug("Checking broker topology after reconnect.")
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+		run(runCtx)
+		cancel()
+	}
+}
+
+// declareMissing will declare the missing entities of the given drift, as `EnsureTopology` would.
+//
+// Declarations run on channels of their own, so that a failure does not disturb publishing.
+func (broker *Broker) declareMissing(ctx context.Context, missing []Drift) *core.Error {
+	if err := broker.acquire(ctx); err != nil {
+		return core.NewErrorFromContext(err)
+	}
+	defer broker.release()
+
+	conn, connErr := broker.getConnection(ctx)
+	if connErr != nil {
+		return broker.handleError(ctx, connErr)
+	}
+
+	for _, entry := range missing {
+		var err error
+		switch entry.Kind {
+		case EntityExchange:
+			for _, exchange := range broker.exchanges {
+				if entry.Name == exchange.name {
+					err = broker.redeclareExchange(conn, exchange)
+				}
+			}
+		case EntityQueue, EntityBinding:
+			for _, binding := range broker.exchangeBindings {
+				if entry.Name == binding.name() {
+					err = withChannel(conn, binding.declare)
+				}
+			}
+			for _, queue := range broker.queues {
+				if entry.Name == queue.name || entry.Name == queue.bindingName() {
+					err = withChannel(conn, queue.declare)
+				}
+			}
+		}
+		if err != nil {
+			return broker.handleChannelError(ctx, err)
+		}
+
+		broker.log.WithFields(logrus.Fields{"kind": entry.Kind, "name": entry.Name}).Warn("Re-created missing broker topology entity.")
+		metrics.TopologyCorrections.Add(entry.Kind, 1)
+	}
+	return nil
+}
+
+// redeclareExchange will declare the given missing exchange, and will bind the exchanges & queues
+// bound to it, or which it is bound to, again, as its bindings are gone along with it.
+//
+// Exchanges & queues which are missing as well are skipped. They are declared & bound when their
+// own drift is corrected.
+func (broker *Broker) redeclareExchange(conn *amqp.Connection, exchange exchangeDeclaration) error {
+	if err := withChannel(conn, exchange.declare); err != nil {
+		return err
+	}
+	for _, binding := range broker.exchangeBindings {
+		if binding.source != exchange.name && binding.destination != exchange.name {
+			continue
+		}
+		err := withChannel(conn, binding.declare)
+		if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.NotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, queue := range broker.queues {
+		if queue.source() != exchange.name {
+			continue
+		}
+		err := withChannel(conn, func(chn *amqp.Channel) error {
+			return chn.QueueBind(queue.name, queue.routingKey, queue.source(), false, queue.matching)
+		})
+		if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.NotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signalReconnect will notify the reconciler that a new connection to the broker was established.
+func (broker *Broker) signalReconnect() {
+	select {
+	case broker.reconnected <- struct{}{}:
+	default: // A reconciliation is already pending.
+	}
+}
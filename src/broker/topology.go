@@ -0,0 +1,689 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+// exchangeDeclaration is the declaration of an exchange.
+type exchangeDeclaration struct {
+	name string
+	kind string
+}
+
+// exchangeBinding is the binding of a domain exchange to its source exchange, by a routing key pattern.
+type exchangeBinding struct {
+	source      string
+	destination string
+	routingKey  string
+}
+
+// queueDeclaration is the declaration of a queue & its binding to an exchange.
+//
+// The default declarations of the event types are generated by protoc-gen-mqservice. See `eventQueues`.
+type queueDeclaration struct {
+	name       string
+	routingKey string
+	exchange   string               // The exchange the queue is bound to. Defaults to the `events` exchange.
+	keep       bool                 // Whether messages are kept until consumed, instead of expiring after `ttlSLA`.
+	matching   amqp.Table           // The `x-match` rules of a binding to a headers exchange, if any.
+	settings   config.QueueSettings // The queue's configured settings. See `configureQueues`.
+}
+
+// EventCatalog will describe every registered event type, along with the queues which the
+// topology may route its events to & the content type its events are published with.
+func (broker *Broker) EventCatalog() []*mq.EventTypeInfo {
+	var catalog []*mq.EventTypeInfo
+	for _, eventType := range mq.EventTypes() {
+		info := &mq.EventTypeInfo{
+			Name:          eventType.Name,
+			Field:         eventType.Field,
+			RoutingKey:    eventType.RoutingKey,
+			ContentType:   contentTypeFor(encodingFor(broker.config, eventType.RoutingKey)),
+			SchemaVersion: eventType.SchemaVersion,
+		}
+		for _, queue := range broker.catalogQueues(eventType.RoutingKey) {
+			info.Queues = append(info.Queues, &mq.QueueInfo{Name: queue.name, TtlMs: queue.ttlMs()})
+		}
+		catalog = append(catalog, info)
+	}
+	return catalog
+}
+
+// Topology describes the topology which `EnsureTopology` declares for a config, and how the events
+// of each registered event type are published to it. See `Describe`.
+type Topology struct {
+	Exchanges      []TopologyExchange  // In declaration order, starting with the `events` exchange.
+	Queues         []TopologyQueue     // In declaration order.
+	Events         []TopologyEventType // In the order of the `SystemEvent.event` oneof fields.
+	RoutingHeaders []string            // The routing headers set on every event, sorted. See `config.RoutingHeaders`.
+}
+
+// TopologyExchange describes an exchange, along with the bindings of other exchanges to it.
+type TopologyExchange struct {
+	Name     string
+	Type     string
+	Bindings []TopologyBinding
+}
+
+// TopologyBinding describes the binding of a destination exchange to a source exchange.
+type TopologyBinding struct {
+	Destination string
+	RoutingKey  string // The routing key pattern of the binding.
+}
+
+// TopologyQueue describes a queue, along with its binding to an exchange.
+type TopologyQueue struct {
+	Name       string
+	Exchange   string                 // The exchange the queue is bound to.
+	RoutingKey string                 // The routing key pattern of its binding, unless bound to a headers exchange.
+	Matching   map[string]interface{} // The `x-match` rules of its binding to a headers exchange, if any.
+	Arguments  map[string]interface{} // The arguments the queue is declared with.
+	TTLMs      int64                  // How long the queue keeps an event. See `mq.QueueInfo`.
+}
+
+// TopologyEventType describes how the events of an event type are published & routed.
+type TopologyEventType struct {
+	Info      *mq.EventTypeInfo // As described by `EventCatalog`.
+	Encoding  string            // One of the `config.Encoding*` values.
+	Encrypted bool              // Whether the events are encrypted. See `config.EventEncryptionRoutingKeys`.
+	Signed    bool              // Whether the events are signed. See `config.EventSigningKeyID`.
+}
+
+// Describe will describe the topology which `EnsureTopology` declares for the given config, and how
+// the events of each registered event type are published to it. It does not need a connection to
+// the broker.
+//
+// An error is returned if the config's topology is not valid. See `New`.
+func Describe(cfg *config.Config) (*Topology, error) {
+	broker, err := configureTopology(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &Topology{}
+	for _, exchange := range broker.exchanges {
+		described := TopologyExchange{Name: exchange.name, Type: exchange.kind}
+		for _, binding := range broker.exchangeBindings {
+			if binding.source == exchange.name {
+				described.Bindings = append(described.Bindings, TopologyBinding{binding.destination, binding.routingKey})
+			}
+		}
+		topology.Exchanges = append(topology.Exchanges, described)
+	}
+	for _, queue := range broker.queues {
+		described := TopologyQueue{Name: queue.name, Exchange: queue.source(), Arguments: queue.arguments(), TTLMs: queue.ttlMs()}
+		if queue.matching == nil {
+			described.RoutingKey = queue.routingKey
+		} else {
+			described.Matching = queue.matching
+		}
+		topology.Queues = append(topology.Queues, described)
+	}
+
+	encrypted := map[string]bool{}
+	for _, routingKey := range cfg.EventEncryptionRoutingKeys {
+		encrypted[routingKey] = true
+	}
+	for _, info := range broker.EventCatalog() {
+		topology.Events = append(topology.Events, TopologyEventType{
+			Info:      info,
+			Encoding:  encodingFor(cfg, info.RoutingKey),
+			Encrypted: encrypted[info.RoutingKey],
+			Signed:    cfg.EventSigningKeyID != "",
+		})
+	}
+	for header := range cfg.RoutingHeaders {
+		topology.RoutingHeaders = append(topology.RoutingHeaders, header)
+	}
+	sort.Strings(topology.RoutingHeaders)
+	return topology, nil
+}
+
+///////////////////////
+// Private Interface //
+
+// configureTopology will build a broker holding only the topology of the given config, validated
+// as `New` does, for describing the topology without a connection to the broker.
+func configureTopology(cfg *config.Config) (*Broker, error) {
+	queues, err := configureQueues(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header bindings: %s", err.Error())
+	}
+	exchanges, exchangeBindings, err := configureExchanges(cfg, queues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange bindings: %s", err.Error())
+	}
+
+	broker := &Broker{config: cfg, exchanges: exchanges, exchangeBindings: exchangeBindings, queues: queues}
+	if err := broker.validateExchanges(); err != nil {
+		return nil, fmt.Errorf("invalid exchange bindings: %s", err.GetMessage())
+	}
+	if err := broker.validateQueues(); err != nil {
+		return nil, fmt.Errorf("invalid queue settings: %s", err.GetMessage())
+	}
+	return broker, nil
+}
+
+// configureExchanges will return the declarations of the exchanges of the topology in declaration
+// order, along with the configured bindings of domain exchanges. Domain exchanges are declared in
+// the order they are first named by the bindings. Those which the given queues are bound to by
+// headers are headers exchanges, and the others are topic exchanges.
+func configureExchanges(cfg *config.Config, queues []queueDeclaration) ([]exchangeDeclaration, []exchangeBinding, error) {
+	exchanges := []exchangeDeclaration{{ExchangeEvents, ExchangeTypeEvents}}
+	if cfg.UnroutedEvents {
+		exchanges = append(exchanges, exchangeDeclaration{ExchangeUnrouted, exchangeTypeFanout})
+	}
+	declared := map[string]bool{ExchangeEvents: true, ExchangeUnrouted: true}
+	headers := map[string]bool{}
+	for _, queue := range queues {
+		headers[queue.source()] = headers[queue.source()] || queue.matching != nil
+	}
+	declare := func(name string) {
+		if declared[name] {
+			return
+		}
+		kind := exchangeTypeTopic
+		if headers[name] {
+			kind = exchangeTypeHeaders
+		}
+		exchanges = append(exchanges, exchangeDeclaration{name, kind})
+		declared[name] = true
+	}
+
+	var bindings []exchangeBinding
+	for _, spec := range cfg.ExchangeBindings {
+		binding, err := config.ParseExchangeBinding(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		declare(binding.Source)
+		declare(binding.Destination)
+		bindings = append(bindings, exchangeBinding{binding.Source, binding.Destination, binding.RoutingKey})
+	}
+
+	// Headers exchanges which are not bound to any exchange are declared as well, so that
+	// `validateExchanges` reports them as unreachable.
+	for _, queue := range queues {
+		if queue.matching != nil {
+			declare(queue.source())
+		}
+	}
+	return exchanges, bindings, nil
+}
+
+// configureQueues will return the default queue declarations, the queue of unrouted events if they
+// are kept & the queues bound to headers exchanges, along with their settings from the given config.
+func configureQueues(cfg *config.Config) ([]queueDeclaration, error) {
+	queues := append([]queueDeclaration{}, eventQueues...)
+	if cfg.UnroutedEvents {
+		queues = append(queues, queueDeclaration{name: QueueUnrouted, exchange: ExchangeUnrouted, keep: true})
+	}
+	for _, spec := range cfg.HeaderBindings {
+		binding, err := config.ParseHeaderBinding(spec)
+		if err != nil {
+			return nil, err
+		}
+		matching := amqp.Table{"x-match": binding.Match}
+		for header, value := range binding.Headers {
+			matching[header] = value
+			if header == mq.HeaderSchemaVersion {
+				// Headers exchanges match values by type too, & the schema version is an integer.
+				version, _ := strconv.ParseInt(value, 10, 64)
+				matching[header] = version
+			}
+		}
+		queues = append(queues, queueDeclaration{name: binding.Queue, exchange: binding.Exchange, matching: matching})
+	}
+	for idx := range queues {
+		queues[idx].settings = cfg.QueueSettings(queues[idx].name)
+	}
+	return queues, nil
+}
+
+// declare will declare the exchange on the given channel.
+func (exchange exchangeDeclaration) declare(chn *amqp.Channel) error {
+	return chn.ExchangeDeclare(exchange.name, exchange.kind, true, false, false, false, nil)
+}
+
+// declare will bind the destination exchange to the source exchange on the given channel.
+func (binding exchangeBinding) declare(chn *amqp.Channel) error {
+	return chn.ExchangeBind(binding.destination, binding.routingKey, binding.source, false, nil)
+}
+
+// name will return the name of the binding, as reported in `Drift`.
+func (binding exchangeBinding) name() string {
+	return fmt.Sprintf("%s -> %s (%s)", binding.source, binding.destination, binding.routingKey)
+}
+
+// source will return the name of the exchange the queue is bound to.
+func (queue queueDeclaration) source() string {
+	if queue.exchange == "" {
+		return ExchangeEvents
+	}
+	return queue.exchange
+}
+
+// declare will declare the queue on the given channel, and will bind it to its exchange.
+func (queue queueDeclaration) declare(chn *amqp.Channel) error {
+	if _, err := chn.QueueDeclare(queue.name, true, false, false, false, queue.arguments()); err != nil {
+		return err
+	}
+	return chn.QueueBind(queue.name, queue.routingKey, queue.source(), false, queue.matching)
+}
+
+// validateExchanges will validate that the configured exchange bindings form a graph rooted at the
+// `events` exchange: every source must be reachable from it, no binding may lead back into it or
+// into the exchange of unrouted events, and the graph may not have cycles. Headers exchanges may
+// only route to queues, which must be bound to them by headers.
+func (broker *Broker) validateExchanges() *core.Error {
+	kinds := map[string]string{}
+	for _, exchange := range broker.exchanges {
+		kinds[exchange.name] = exchange.kind
+	}
+	reachable := map[string]bool{ExchangeEvents: true}
+	destinations := map[string][]string{}
+	for _, binding := range broker.exchangeBindings {
+		if binding.destination == ExchangeEvents || binding.destination == ExchangeUnrouted {
+			return core.NewInvalidArgument("exchange", fmt.Sprintf("Binding '%s': exchange '%s' can not be a destination.", binding.name(), binding.destination))
+		}
+		if kinds[binding.source] == exchangeTypeHeaders {
+			return core.NewInvalidArgument("exchange", fmt.Sprintf("Binding '%s': headers exchange '%s' can only be bound to queues.", binding.name(), binding.source))
+		}
+		destinations[binding.source] = append(destinations[binding.source], binding.destination)
+	}
+
+	// Walk the graph from the `events` exchange, looking for cycles along the way.
+	visiting := map[string]bool{}
+	var walk func(exchange string) *core.Error
+	walk = func(exchange string) *core.Error {
+		visiting[exchange] = true
+		defer delete(visiting, exchange)
+		for _, destination := range destinations[exchange] {
+			if visiting[destination] {
+				return core.NewInvalidArgument("exchange", fmt.Sprintf("The exchange bindings form a cycle through exchange '%s'.", destination))
+			}
+			if reachable[destination] {
+				continue
+			}
+			reachable[destination] = true
+			if err := walk(destination); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(ExchangeEvents); err != nil {
+		return err
+	}
+
+	for _, binding := range broker.exchangeBindings {
+		if !reachable[binding.source] {
+			return core.NewInvalidArgument("exchange", fmt.Sprintf("Binding '%s': exchange '%s' is not bound to the '%s' exchange.", binding.name(), binding.source, ExchangeEvents))
+		}
+	}
+	for _, queue := range broker.queues {
+		if queue.matching == nil {
+			continue
+		}
+		if kinds[queue.source()] != exchangeTypeHeaders {
+			return core.NewInvalidArgument("exchange", fmt.Sprintf("Binding '%s': exchange '%s' is not a headers exchange.", queue.bindingName(), queue.source()))
+		}
+		if !reachable[queue.source()] {
+			return core.NewInvalidArgument("exchange", fmt.Sprintf("Binding '%s': exchange '%s' is not bound to the '%s' exchange.", queue.bindingName(), queue.source(), ExchangeEvents))
+		}
+	}
+	return nil
+}
+
+// validateQueues will validate that no queue is declared twice, that the configured queue settings
+// name known queues, and that the settings of each queue are compatible with each other & its type.
+func (broker *Broker) validateQueues() *core.Error {
+	known := map[string]bool{}
+	for _, queue := range broker.queues {
+		if known[queue.name] {
+			return core.NewInvalidArgument("queue", fmt.Sprintf("Queue '%s' is declared more than once.", queue.name))
+		}
+		known[queue.name] = true
+	}
+	cfg := broker.config
+	var configured []string
+	for _, settings := range []map[string]string{cfg.QueueTypes, cfg.QueueOverflows} {
+		for name := range settings {
+			configured = append(configured, name)
+		}
+	}
+	for _, settings := range []map[string]int64{cfg.QueueMaxLengths, cfg.QueueMaxBytes} {
+		for name := range settings {
+			configured = append(configured, name)
+		}
+	}
+	for name := range cfg.QueueMaxPriorities {
+		configured = append(configured, name)
+	}
+	configured = append(append(configured, cfg.QueueLazy...), cfg.QueueSingleActiveConsumer...)
+	for _, name := range configured {
+		if !known[name] {
+			return core.NewInvalidArgument("queue", fmt.Sprintf("Settings are configured for unknown queue '%s'.", name))
+		}
+	}
+
+	for _, queue := range broker.queues {
+		if err := queue.validate(); err != nil {
+			return core.NewInvalidArgument("queue", fmt.Sprintf("Queue '%s': %s", queue.name, err.Error()))
+		}
+	}
+	return nil
+}
+
+// eventRoute is where the topology routes a single event to.
+type eventRoute struct {
+	headers amqp.Table         // The event's routing headers. See `routingHeaders`.
+	queues  []queueDeclaration // The queues the event is routed to. See `routedQueues`.
+}
+
+// routeEvent will resolve the routing headers of the given event & the queues which the topology
+// routes it to, and will validate the given options against those queues.
+func (broker *Broker) routeEvent(message mq.SystemEventMessage, reqCtx *core.Context, opts PublishOptions) (eventRoute, *core.Error) {
+	headers, err := broker.routingHeaders(message, reqCtx)
+	if err != nil {
+		broker.log.Errorf("Error reading routing headers: %T: %s", err, err.Error())
+		return eventRoute{}, core.NewError(core.CodeInternal)
+	}
+	// Queues may also be bound by the schema version which every event carries.
+	matched := amqp.Table{mq.HeaderSchemaVersion: int64(mq.EventTypeOf(message).SchemaVersion)}
+	for header, value := range headers {
+		matched[header] = value
+	}
+	route := eventRoute{headers, broker.routedQueues(message.RoutingKey(), matched)}
+	if err := validatePublishOptions(route.queues, opts); err != nil {
+		return route, err
+	}
+	return route, nil
+}
+
+// routedQueues will return the queues which the topology routes an event with the given routing
+// key & routing headers to, following the exchange bindings from the `events` exchange as the
+// broker would. Events which reach no queue are routed to the queue of unrouted events, if kept.
+func (broker *Broker) routedQueues(routingKey string, headers amqp.Table) []queueDeclaration {
+	routes := func(queue queueDeclaration, kind string) bool {
+		return queue.routes(kind, routingKey, headers)
+	}
+	queues := broker.reachedQueues(ExchangeEvents, routingKey, routes)
+	if len(queues) == 0 {
+		queues = broker.reachedQueues(ExchangeUnrouted, routingKey, routes)
+	}
+	return queues
+}
+
+// catalogQueues will return the queues which the topology may route events with the given routing
+// key to. Whether a queue bound to a headers exchange gets an event depends on the event's routing
+// headers, so those queues are included whenever the events reach their exchange. The queue of
+// unrouted events is included, if kept, unless the events always reach another queue.
+func (broker *Broker) catalogQueues(routingKey string) []queueDeclaration {
+	queues := broker.reachedQueues(ExchangeEvents, routingKey, func(queue queueDeclaration, kind string) bool {
+		return kind == exchangeTypeHeaders || queue.routes(kind, routingKey, nil)
+	})
+
+	// Events without any routing headers only reach the queues which get every event.
+	for _, queue := range broker.routedQueues(routingKey, amqp.Table{}) {
+		if queue.source() == ExchangeUnrouted {
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+// reachedQueues will return the queues which events with the given routing key reach from the given
+// exchange, following the exchange bindings. Each queue bound to a reached exchange is included if
+// the given function tells that its binding routes the events, given the exchange type.
+func (broker *Broker) reachedQueues(exchange, routingKey string, routes func(queue queueDeclaration, kind string) bool) []queueDeclaration {
+	kinds := map[string]string{}
+	for _, declaration := range broker.exchanges {
+		kinds[declaration.name] = declaration.kind
+	}
+
+	// The exchange graph has no cycles, as `validateExchanges` ensures.
+	var queues []queueDeclaration
+	routed := map[string]bool{}
+	var route func(exchange string)
+	route = func(exchange string) {
+		for _, queue := range broker.queues {
+			if queue.source() == exchange && !routed[queue.name] && routes(queue, kinds[exchange]) {
+				routed[queue.name] = true
+				queues = append(queues, queue)
+			}
+		}
+		for _, binding := range broker.exchangeBindings {
+			if binding.source == exchange && matchesTopic(binding.routingKey, routingKey) {
+				route(binding.destination)
+			}
+		}
+	}
+	route(exchange)
+	return queues
+}
+
+// validatePublishOptions will validate the priority & expiration of the given options against the
+// given queues, which the event is routed to.
+func validatePublishOptions(queues []queueDeclaration, opts PublishOptions) *core.Error {
+	if opts.Priority > MaxPriority {
+		return core.NewInvalidArgument("priority", fmt.Sprintf("The priority must be between 0 & %d.", MaxPriority))
+	}
+	if opts.Expiration < 0 || opts.Expiration > 0 && opts.Expiration < time.Millisecond {
+		return core.NewInvalidArgument("expirationMs", "The expiration must be a positive number of milliseconds.")
+	}
+
+	for _, queue := range queues {
+		if int(opts.Priority) > queue.settings.MaxPriority {
+			return core.NewInvalidArgument("priority", fmt.Sprintf("Queue '%s' supports priorities up to %d.", queue.name, queue.settings.MaxPriority))
+		}
+		if opts.Expiration > 0 && queue.settings.Type == config.QueueStream {
+			return core.NewInvalidArgument("expirationMs", fmt.Sprintf("Queue '%s' is a stream, which does not expire events.", queue.name))
+		}
+		if ttl, ok := queue.messageTTL(); ok && opts.Expiration > ttl {
+			return core.NewInvalidArgument("expirationMs", fmt.Sprintf("Queue '%s' expires events after %dms, which the expiration may not exceed.", queue.name, ttl/time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// rejectingQueues will return the names of the given queues which reject publishings once full.
+func rejectingQueues(queues []queueDeclaration) []string {
+	var names []string
+	for _, queue := range queues {
+		overflow := queue.settings.Overflow
+		if overflow == config.OverflowRejectPublish || overflow == config.OverflowRejectPublishDLX {
+			names = append(names, queue.name)
+		}
+	}
+	return names
+}
+
+// routes will check if the queue's binding routes an event with the given routing key & routing
+// headers, given the type of the exchange it is bound to.
+func (queue queueDeclaration) routes(kind, routingKey string, headers amqp.Table) bool {
+	switch kind {
+	case exchangeTypeFanout:
+		return true
+	case exchangeTypeHeaders:
+		return matchesHeaders(queue.matching, headers)
+	default:
+		return matchesTopic(queue.routingKey, routingKey)
+	}
+}
+
+// messageTTL will return how long the queue keeps an event before it expires, if its events expire.
+func (queue queueDeclaration) messageTTL() (time.Duration, bool) {
+	if queue.keep || queue.settings.Type == config.QueueStream {
+		return 0, false
+	}
+	return time.Duration(ttlSLA) * time.Millisecond, true
+}
+
+// ttlMs will return how long the queue keeps an event, in milliseconds: its message TTL, or the max
+// age of a stream. Zero if the queue keeps events until they are consumed.
+func (queue queueDeclaration) ttlMs() int64 {
+	switch {
+	case queue.keep:
+		return 0
+	case queue.settings.Type == config.QueueStream:
+		return ttlSLA / 1000 * 1000 // `x-max-age` is declared in whole seconds.
+	}
+	ttl, _ := queue.messageTTL()
+	return int64(ttl / time.Millisecond)
+}
+
+// matchesTopic will check if the given routing key matches the given topic binding pattern, in which
+// `*` matches a single word & `#` matches zero or more words.
+func matchesTopic(pattern, routingKey string) bool {
+	var match func(pattern, words []string) bool
+	match = func(pattern, words []string) bool {
+		switch {
+		case len(pattern) == 0:
+			return len(words) == 0
+		case pattern[0] == "#":
+			for skip := 0; skip <= len(words); skip++ {
+				if match(pattern[1:], words[skip:]) {
+					return true
+				}
+			}
+			return false
+		case len(words) == 0:
+			return false
+		case pattern[0] == "*" || pattern[0] == words[0]:
+			return match(pattern[1:], words[1:])
+		default:
+			return false
+		}
+	}
+	return match(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+// matchesHeaders will check if the given headers match the given `x-match` rules, as a headers
+// exchange would. Rules on headers starting with `x-` are ignored, as the broker does.
+func matchesHeaders(matching, headers amqp.Table) bool {
+	all := matching["x-match"] != config.MatchAny
+	for header, expected := range matching {
+		if strings.HasPrefix(header, "x-") {
+			continue
+		}
+		actual, ok := headers[header]
+		matched := ok && fmt.Sprint(actual) == fmt.Sprint(expected)
+		if matched && !all {
+			return true
+		}
+		if !matched && all {
+			return false
+		}
+	}
+	return all
+}
+
+// validate will validate that the queue's settings are compatible with each other & its type.
+func (queue queueDeclaration) validate() error {
+	settings := queue.settings
+	if settings.Overflow != "" && settings.MaxLength == 0 && settings.MaxBytes == 0 {
+		return fmt.Errorf("an overflow behaviour needs a max length or max bytes")
+	}
+
+	switch settings.Type {
+	case config.QueueQuorum:
+		switch {
+		case settings.Lazy:
+			return fmt.Errorf("quorum queues can not be lazy")
+		case settings.MaxPriority > 0:
+			return fmt.Errorf("quorum queues do not support priorities")
+		case settings.Overflow == config.OverflowRejectPublishDLX:
+			return fmt.Errorf("quorum queues do not support the '%s' overflow behaviour", config.OverflowRejectPublishDLX)
+		}
+	case config.QueueStream:
+		switch {
+		case settings.MaxLength > 0:
+			return fmt.Errorf("streams do not support a max length, only max bytes")
+		case settings.Overflow != "":
+			return fmt.Errorf("streams do not support overflow behaviours")
+		case settings.Lazy:
+			return fmt.Errorf("streams can not be lazy")
+		case settings.MaxPriority > 0:
+			return fmt.Errorf("streams do not support priorities")
+		case settings.SingleActiveConsumer:
+			return fmt.Errorf("streams do not support single active consumer over AMQP")
+		}
+	}
+	return nil
+}
+
+// arguments will return the arguments which the queue is declared with.
+//
+// Classic queues are declared without a queue type, as they always have been. Streams do not
+// support message TTLs, so their messages are retained for as long instead. Queues which keep
+// their messages have neither.
+func (queue queueDeclaration) arguments() amqp.Table {
+	settings := queue.settings
+	args := amqp.Table{}
+	if settings.Type != config.QueueClassic {
+		args["x-queue-type"] = settings.Type
+	}
+	switch {
+	case queue.keep:
+	case settings.Type == config.QueueStream:
+		args["x-max-age"] = fmt.Sprintf("%ds", ttlSLA/1000)
+	default:
+		args["x-message-ttl"] = ttlSLA
+	}
+
+	if settings.MaxLength > 0 {
+		args["x-max-length"] = settings.MaxLength
+	}
+	if settings.MaxBytes > 0 {
+		args["x-max-length-bytes"] = settings.MaxBytes
+	}
+	if settings.Overflow != "" {
+		args["x-overflow"] = settings.Overflow
+	}
+	if settings.Lazy {
+		args["x-queue-mode"] = "lazy"
+	}
+	if settings.MaxPriority > 0 {
+		args["x-max-priority"] = int32(settings.MaxPriority)
+	}
+	if settings.SingleActiveConsumer {
+		args["x-single-active-consumer"] = true
+	}
+	return args
+}
+
+// bindingName will return the name which drift of the queue's binding is reported under.
+func (queue queueDeclaration) bindingName() string {
+	return fmt.Sprintf("%s -> %s (%s)", queue.source(), queue.name, queue.bindingKey())
+}
+
+// bindingKey will describe what the queue's binding matches: its routing key, or its `x-match`
+// rules if it is bound to a headers exchange. Rules are sorted by header, so that the key of a
+// binding is stable.
+func (queue queueDeclaration) bindingKey() string {
+	if queue.matching == nil {
+		return queue.routingKey
+	}
+	return matchingKey(queue.matching)
+}
+
+// matchingKey will describe the given `x-match` rules as `header=value&other=value`, sorted by header.
+func matchingKey(matching map[string]interface{}) string {
+	var rules []string
+	for header, value := range matching {
+		rules = append(rules, fmt.Sprintf("%s=%v", header, value))
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, "&")
+}
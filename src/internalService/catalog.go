@@ -0,0 +1,30 @@
+package internalService
+
+import (
+	"context"
+
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+// ListEventTypes will describe every known event type, along with its routing key, bound queues & content type.
+func (service *InternalMQService) ListEventTypes(ctx context.Context, req *mq.ListEventTypesRequest) (*mq.ListEventTypesResponse, error) {
+	service.log.Debug("Handling request to list event types.")
+	return &mq.ListEventTypesResponse{EventTypes: service.broker.EventCatalog()}, nil
+}
+
+// GetFileDescriptorSet will return the proto descriptors of all events, for decoding event payloads dynamically.
+func (service *InternalMQService) GetFileDescriptorSet(ctx context.Context, req *mq.GetFileDescriptorSetRequest) (*mq.GetFileDescriptorSetResponse, error) {
+	response := &mq.GetFileDescriptorSetResponse{Error: nil}
+	service.log.Debug("Handling request to get the event file descriptor set.")
+
+	set, err := mq.FileDescriptorSet()
+	if err != nil {
+		service.log.Errorf("Error building file descriptor set: %s", err.Error())
+		response.Error = service.fail(ctx, core.NewError(core.CodeInternal))
+		return response, nil
+	}
+
+	response.FileDescriptorSet = set
+	return response, nil
+}
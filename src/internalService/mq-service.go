@@ -2,62 +2,103 @@ package internalService
 
 import (
 	"context"
+	"math"
+	"strconv"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
+	"gitlab.com/project-leaf/mq-service-go/src/auth"
 	"gitlab.com/project-leaf/mq-service-go/src/broker"
 	"gitlab.com/project-leaf/mq-service-go/src/config"
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
 	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
 )
 
 // InternalMQService is the type which implements our `mq-service.proto::InternalMQService`.
+//
+// Its publish handlers are generated by protoc-gen-mqservice.
+//
+// Failures are returned to callers via the response's `Error` field, never as a gRPC error. The
+// gRPC status code which matches the failure is returned on the call in the `TrailerGRPCStatus`
+// trailer, and is copied into the error's `grpc_code` meta entry. The only exception is an
+// unauthenticated request, which is rejected before it reaches this type. See
+// `auth.Authenticator.UnaryInterceptor`.
 type InternalMQService struct {
-	config *config.Config
-	log    *logrus.Logger
-	broker *broker.Broker
+	config    *config.Config
+	log       *logrus.Logger
+	publisher broker.Publisher
+	broker    *broker.Broker
 }
 
 // New will build and return an `InternalMQService` instance.
-func New(cfg *config.Config, log *logrus.Logger, broker *broker.Broker) *InternalMQService {
-	return &InternalMQService{cfg, log, broker}
+//
+// Events are published via the given publisher, while the given broker describes the topology.
+func New(cfg *config.Config, log *logrus.Logger, publisher broker.Publisher, mqBroker *broker.Broker) *InternalMQService {
+	return &InternalMQService{cfg, log, publisher, mqBroker}
 }
 
-// PubPhotoScanUploaded will publish an `PhotoScanUploaded` event to the central event bus according to the given request.
-func (service *InternalMQService) PubPhotoScanUploaded(ctx context.Context, req *mq.PubPhotoScanUploadedRequest) (*mq.PubPhotoScanUploadedResponse, error) {
-	response := &mq.PubPhotoScanUploadedResponse{Error: nil}
-	service.log.Debug("Handling request to publish a PhotoScanUploaded event.")
+// TrailerGRPCStatus is the trailer holding the numeric gRPC status code which matches the
+// response's `Error`, as the `grpc-status` trailer would. It is not set on successful calls.
+const TrailerGRPCStatus = "x-grpc-status"
 
-	// Build the event object and send it to the broker.
-	event := &mq.SystemEvent_PhotoScanUploaded{
-		PhotoScanUploaded: &mq.EventPhotoScanUploaded{
-			Id: req.GetId(),
-		},
+///////////////////////
+// Private Interface //
+
+// publishRequest is implemented by every publish request, holding the fields common to all of them.
+type publishRequest interface {
+	GetContext() *core.Context
+	GetOccurredAt() *timestamp.Timestamp
+	GetCausationId() string
+	GetPriority() uint32
+	GetExpirationMs() int64
+}
+
+// publish will publish the given event according to the given request.
+//
+// This routine is called by the handlers generated by protoc-gen-mqservice.
+func (service *InternalMQService) publish(ctx context.Context, event mq.SystemEventMessage, req publishRequest) *core.Error {
+	opts, err := publishOptions(ctx, req)
+	if err != nil {
+		return service.fail(ctx, err)
 	}
-	if err := service.broker.PublishEvent(event, req.GetContext()); err != nil {
-		response.Error = err
-		return response, nil
+	if err := service.publisher.PublishEvent(ctx, event, req.GetContext(), opts); err != nil {
+		return service.fail(ctx, err)
 	}
-
-	service.log.Debug("PhotoScanUploaded event successfully published.")
-	return response, nil
+	return nil
 }
 
-// PubPhotoScanSampled will publish an `PhotoScanSampled` event to the central event bus according to the given request.
-func (service *InternalMQService) PubPhotoScanSampled(ctx context.Context, req *mq.PubPhotoScanSampledRequest) (*mq.PubPhotoScanSampledResponse, error) {
-	response := &mq.PubPhotoScanSampledResponse{Error: nil}
-	service.log.Debug("Handling request to publish a PhotoScanSampled event.")
+// fail will return the gRPC status code which matches the given error on the call of the given
+// context, and will return the error. See `TrailerGRPCStatus`.
+func (service *InternalMQService) fail(ctx context.Context, err *core.Error) *core.Error {
+	trailer := metadata.Pairs(TrailerGRPCStatus, strconv.Itoa(int(err.GRPCCode())))
+	if setErr := grpc.SetTrailer(ctx, trailer); setErr != nil {
+		service.log.Debugf("Error setting the gRPC status trailer: %s", setErr.Error())
+	}
+	return err
+}
 
-	// Build the event object and send it to the broker.
-	event := &mq.SystemEvent_PhotoScanSampled{
-		PhotoScanSampled: &mq.EventPhotoScanSampled{
-			Id: req.GetId(),
-		},
+// publishOptions will build the options for publishing an event from the fields common to all publish requests.
+//
+// The event's producer is the authenticated caller of the request. The options are validated when
+// the event is published. See `broker.Broker.PublishEvent`.
+func publishOptions(ctx context.Context, req publishRequest) (broker.PublishOptions, *core.Error) {
+	opts := broker.PublishOptions{Producer: auth.Producer(ctx), CausationID: req.GetCausationId()}
+	if occurredAt := req.GetOccurredAt(); occurredAt != nil {
+		at, err := ptypes.Timestamp(occurredAt)
+		if err != nil {
+			return opts, core.NewInvalidArgument("occurredAt", "The occurred-at time is not a valid timestamp.")
+		}
+		opts.OccurredAt = at
 	}
-	if err := service.broker.PublishEvent(event, req.GetContext()); err != nil {
-		response.Error = err
-		return response, nil
+	opts.Priority = req.GetPriority()
+	if req.GetExpirationMs() > int64(math.MaxInt64/time.Millisecond) {
+		return opts, core.NewInvalidArgument("expirationMs", "The expiration is out of range.")
 	}
-
-	service.log.Debug("PhotoScanSampled event successfully published.")
-	return response, nil
+	opts.Expiration = time.Duration(req.GetExpirationMs()) * time.Millisecond
+	return opts, nil
 }
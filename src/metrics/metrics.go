@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"expvar"
+)
+
+// All metrics are published via `expvar`, and are served by the admin listener at `/debug/vars`.
+var (
+	// BrokerNode is the name of the broker node currently in use.
+	BrokerNode = expvar.NewString("broker_node")
+
+	// BrokerConnections is the number of connections established, keyed by broker node.
+	BrokerConnections = expvar.NewMap("broker_connections")
+
+	// BrokerDialFailures is the number of failed dial attempts, keyed by broker node.
+	BrokerDialFailures = expvar.NewMap("broker_dial_failures")
+
+	// BrokerConnectionsLost is the number of connections lost unexpectedly, keyed by broker node.
+	BrokerConnectionsLost = expvar.NewMap("broker_connections_lost")
+
+	// BrokerBlocked is `1` while the broker has blocked the current connection, else `0`.
+	BrokerBlocked = expvar.NewInt("broker_blocked")
+
+	// BrokerBlockedTotal is the number of times the broker has blocked a connection.
+	BrokerBlockedTotal = expvar.NewInt("broker_blocked_total")
+
+	// BrokerCircuitState is the state of the broker circuit breaker: `closed`, `open` or `half-open`.
+	BrokerCircuitState = expvar.NewString("broker_circuit_state")
+
+	// BrokerCircuitTransitions is the number of broker circuit breaker transitions, keyed by the state entered.
+	BrokerCircuitTransitions = expvar.NewMap("broker_circuit_transitions")
+
+	// QueueFullRejections is the number of events nacked by the broker as a queue was full, keyed by queue.
+	QueueFullRejections = expvar.NewMap("queue_full_rejections")
+
+	// UnroutedEvents is the number of unrouted events waiting in the `events.unrouted` queue, as of the
+	// latest topology verification.
+	UnroutedEvents = expvar.NewInt("unrouted_events")
+
+	// TopologyDrift is the number of differences found by the latest broker topology verification.
+	TopologyDrift = expvar.NewInt("topology_drift")
+
+	// TopologyMigrations is the number of queue migrations applied, keyed by outcome: `applied` or `failed`.
+	TopologyMigrations = expvar.NewMap("topology_migrations")
+
+	// TopologyCorrections is the number of missing topology entities re-created by the reconciler, keyed by kind.
+	TopologyCorrections = expvar.NewMap("topology_corrections")
+
+	// TopologyReconcileFailures is the number of topology reconciliations which failed.
+	TopologyReconcileFailures = expvar.NewInt("topology_reconcile_failures")
+
+	// PublishQueueDepth is the number of events waiting in the publish queue.
+	PublishQueueDepth = expvar.NewInt("publish_queue_depth")
+
+	// PublishQueueRejections is the number of events rejected because the publish queue was full.
+	PublishQueueRejections = expvar.NewInt("publish_queue_rejections")
+)
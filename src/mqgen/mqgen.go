@@ -0,0 +1,227 @@
+package mqgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+
+const (
+	// systemEventMessage is the name of the message whose `event` oneof declares the event types.
+	systemEventMessage = "SystemEvent"
+	// eventMessagePrefix prefixes the name of each event message, e.g. `EventPhotoScanUploaded`.
+	eventMessagePrefix = "Event"
+	// publishMethodPrefix prefixes the name of the RPC which publishes each event, e.g. `PubPhotoScanUploaded`.
+	publishMethodPrefix = "Pub"
+
+	// The packages of the generated files, relative to the `src` directory.
+	dirRoutingKeys = "proto/mq"
+	dirHandlers    = "internalService"
+	dirTopology    = "broker"
+)
+
+// outputs are the packages of the generated files & the templates they are rendered from, in the
+// order they are generated. Each file is named after its proto file, e.g. `mq-service.mq.go`.
+var outputs = []struct {
+	dir      string
+	template *template.Template
+}{
+	{dirRoutingKeys, routingKeysTemplate},
+	{dirHandlers, handlersTemplate},
+	{dirTopology, topologyTemplate},
+}
+
+// event is an event type declared by the `SystemEvent.event` oneof.
+//
+// Its routing key & queue are read from the `(mq.routingKey)` & `(mq.queue)` message options.
+// See `mq.EventType`.
+type event struct {
+	Name       string // The Go name of the oneof field, e.g. `PhotoScanUploaded`.
+	Message    string // The Go name of the event message, e.g. `EventPhotoScanUploaded`.
+	RoutingKey string
+	Queue      string
+
+	Handler *handler // The RPC publishing the event, if the service declares one.
+}
+
+// handler is an RPC which publishes an event.
+type handler struct {
+	Method   string
+	Request  string
+	Response string
+	Fields   []string // The Go names of the event message fields, copied from the same fields of the request.
+}
+
+// Generate will generate the routing key methods, the `InternalMQService` handlers & the default
+// queue declarations for the events declared by the files to generate in the given request.
+//
+// Failures are reported through the response's `Error` field, as protoc expects of plugins.
+func Generate(req *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	files, err := generate(req)
+	if err != nil {
+		return &plugin.CodeGeneratorResponse{Error: proto.String(err.Error())}
+	}
+	return &plugin.CodeGeneratorResponse{File: files}
+}
+
+///////////////////////
+// Private Interface //
+
+// generate is the implementation of `Generate`.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, name := range req.GetFileToGenerate() {
+		var file *descriptor.FileDescriptorProto
+		for _, candidate := range req.GetProtoFile() {
+			if candidate.GetName() == name {
+				file = candidate
+			}
+		}
+		if file == nil {
+			return nil, fmt.Errorf("file to generate '%s' is missing from the request", name)
+		}
+
+		events, err := readEvents(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		for _, output := range outputs {
+			if output.dir == dirHandlers && !hasHandlers(events) {
+				continue
+			}
+			filename := path.Join(output.dir, strings.TrimSuffix(path.Base(name), ".proto")+".mq.go")
+			content, err := render(output.template, name, events)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", filename, err.Error())
+			}
+			files = append(files, &plugin.CodeGeneratorResponse_File{Name: proto.String(filename), Content: proto.String(content)})
+		}
+	}
+	return files, nil
+}
+
+// readEvents will read the event types declared by the `SystemEvent.event` oneof of the given file.
+func readEvents(file *descriptor.FileDescriptorProto) ([]*event, error) {
+	messages := map[string]*descriptor.DescriptorProto{}
+	for _, message := range file.GetMessageType() {
+		messages[fmt.Sprintf(".%s.%s", file.GetPackage(), message.GetName())] = message
+	}
+	systemEvent, ok := messages[fmt.Sprintf(".%s.%s", file.GetPackage(), systemEventMessage)]
+	if !ok {
+		return nil, nil
+	}
+
+	var events []*event
+	for _, field := range systemEvent.GetField() {
+		if field.OneofIndex == nil {
+			continue
+		}
+		message, ok := messages[field.GetTypeName()]
+		if !ok {
+			return nil, fmt.Errorf("event message '%s' must be declared in the same file as '%s'", field.GetTypeName(), systemEventMessage)
+		}
+
+		evt := &event{
+			Name:       generator.CamelCase(field.GetName()),
+			Message:    generator.CamelCase(message.GetName()),
+			RoutingKey: mq.DefaultRoutingKey(field.GetName()),
+		}
+		if options := message.GetOptions(); options != nil {
+			if value, err := proto.GetExtension(options, mq.E_RoutingKey); err == nil {
+				evt.RoutingKey = *value.(*string)
+			}
+			if value, err := proto.GetExtension(options, mq.E_Queue); err == nil {
+				evt.Queue = *value.(*string)
+			}
+		}
+		if evt.Queue == "" {
+			evt.Queue = evt.RoutingKey
+		}
+
+		handler, err := readHandler(file, messages, message, strings.TrimPrefix(evt.Message, eventMessagePrefix))
+		if err != nil {
+			return nil, err
+		}
+		evt.Handler = handler
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// readHandler will read the RPC which publishes the event with the given message & name, if any.
+//
+// Each field of the event message must have a field of the same name in the RPC's request.
+func readHandler(file *descriptor.FileDescriptorProto, messages map[string]*descriptor.DescriptorProto, message *descriptor.DescriptorProto, name string) (*handler, error) {
+	for _, service := range file.GetService() {
+		for _, method := range service.GetMethod() {
+			if method.GetName() != publishMethodPrefix+name {
+				continue
+			}
+
+			request, ok := messages[method.GetInputType()]
+			if !ok {
+				return nil, fmt.Errorf("request message '%s' must be declared in the same file as '%s'", method.GetInputType(), systemEventMessage)
+			}
+			handler := &handler{
+				Method:   generator.CamelCase(method.GetName()),
+				Request:  generator.CamelCase(request.GetName()),
+				Response: generator.CamelCase(strings.TrimPrefix(method.GetOutputType(), fmt.Sprintf(".%s.", file.GetPackage()))),
+			}
+			for _, field := range message.GetField() {
+				if !hasField(request, field) {
+					return nil, fmt.Errorf("request '%s' has no field '%s' matching event message '%s'", request.GetName(), field.GetName(), message.GetName())
+				}
+				handler.Fields = append(handler.Fields, generator.CamelCase(field.GetName()))
+			}
+			return handler, nil
+		}
+	}
+	return nil, nil
+}
+
+// hasField will check if the given message has a field of the same name, type & label as the given field.
+func hasField(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) bool {
+	for _, candidate := range message.GetField() {
+		if candidate.GetName() == field.GetName() {
+			return candidate.GetType() == field.GetType() && candidate.GetTypeName() == field.GetTypeName() && candidate.GetLabel() == field.GetLabel()
+		}
+	}
+	return false
+}
+
+// hasHandlers will check if any of the given events has a publish RPC.
+func hasHandlers(events []*event) bool {
+	for _, evt := range events {
+		if evt.Handler != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// render will render & format the given template for the events of the given proto file.
+func render(tmpl *template.Template, source string, events []*event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{"Source": source, "Events": events}); err != nil {
+		return "", err
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
@@ -0,0 +1,151 @@
+package mqgen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	_ "gitlab.com/project-leaf/mq-service-go/src/proto/mq" // Registers `mq-service.proto`.
+)
+
+var update = flag.Bool("update", false, "update the golden files with the generated output")
+
+func TestGenerateGolden(t *testing.T) {
+	cases := map[string]*plugin.CodeGeneratorRequest{
+		"mq-service": {FileToGenerate: []string{"mq-service.proto"}, ProtoFile: []*descriptor.FileDescriptorProto{registeredFile(t, "mq-service.proto")}},
+		"defaults":   {FileToGenerate: []string{"events.proto"}, ProtoFile: []*descriptor.FileDescriptorProto{eventsFile(nil)}},
+	}
+
+	for name, req := range cases {
+		resp := Generate(req)
+		if resp.Error != nil {
+			t.Fatalf("%s: unexpected error: %s", name, resp.GetError())
+		}
+		for _, file := range resp.GetFile() {
+			golden := filepath.Join("testdata", name, file.GetName()+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(golden, []byte(file.GetContent()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err.Error())
+			}
+			if file.GetContent() != string(want) {
+				t.Errorf("%s: generated %s differs from %s. Run `go test -update` if the change is intended.\n%s", name, file.GetName(), golden, file.GetContent())
+			}
+		}
+	}
+}
+
+func TestGeneratedFilesAreCurrent(t *testing.T) {
+	resp := Generate(&plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"mq-service.proto"},
+		ProtoFile:      []*descriptor.FileDescriptorProto{registeredFile(t, "mq-service.proto")},
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.GetError())
+	}
+	for _, file := range resp.GetFile() {
+		current, err := ioutil.ReadFile(filepath.Join("..", file.GetName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.GetContent() != string(current) {
+			t.Errorf("src/%s is out of date. Regenerate it with protoc-gen-mqservice.", file.GetName())
+		}
+	}
+}
+
+func TestGenerateRequestFieldMismatch(t *testing.T) {
+	// The request's `id` field is a number, while the event's is a string.
+	request := &descriptor.DescriptorProto{
+		Name:  proto.String("PubThingHappenedRequest"),
+		Field: []*descriptor.FieldDescriptorProto{field("id", 1, descriptor.FieldDescriptorProto_TYPE_UINT64, "", nil)},
+	}
+	resp := Generate(&plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"events.proto"},
+		ProtoFile:      []*descriptor.FileDescriptorProto{eventsFile(request)},
+	})
+	if !strings.Contains(resp.GetError(), "has no field 'id'") {
+		t.Fatalf("expected a field mismatch error, got: %q", resp.GetError())
+	}
+}
+
+// registeredFile will decode the descriptor of the given registered proto file.
+func registeredFile(t *testing.T, filename string) *descriptor.FileDescriptorProto {
+	reader, err := gzip.NewReader(bytes.NewReader(proto.FileDescriptor(filename)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(raw, file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// eventsFile will build the descriptor of a file declaring a single event without any options,
+// published by an RPC with the given request, if any.
+func eventsFile(request *descriptor.DescriptorProto) *descriptor.FileDescriptorProto {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("events.proto"),
+		Package: proto.String("mq"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:      proto.String("SystemEvent"),
+				Field:     []*descriptor.FieldDescriptorProto{field("thingHappened", 1, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".mq.EventThingHappened", proto.Int32(0))},
+				OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("event")}},
+			},
+			{
+				Name:  proto.String("EventThingHappened"),
+				Field: []*descriptor.FieldDescriptorProto{field("id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", nil)},
+			},
+		},
+	}
+	if request != nil {
+		file.MessageType = append(file.MessageType, request, &descriptor.DescriptorProto{Name: proto.String("PubThingHappenedResponse")})
+		file.Service = []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Events"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("PubThingHappened"),
+				InputType:  proto.String(".mq." + request.GetName()),
+				OutputType: proto.String(".mq.PubThingHappenedResponse"),
+			}},
+		}}
+	}
+	return file
+}
+
+// field will build the descriptor of an optional field.
+func field(name string, number int32, fieldType descriptor.FieldDescriptorProto_Type, typeName string, oneofIndex *int32) *descriptor.FieldDescriptorProto {
+	fieldDescriptor := &descriptor.FieldDescriptorProto{
+		Name:       proto.String(name),
+		Number:     proto.Int32(number),
+		Label:      descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:       fieldType.Enum(),
+		OneofIndex: oneofIndex,
+	}
+	if typeName != "" {
+		fieldDescriptor.TypeName = proto.String(typeName)
+	}
+	return fieldDescriptor
+}
@@ -0,0 +1,66 @@
+package mqgen
+
+import (
+	"text/template"
+)
+
+// header is the header of every generated file.
+const header = `// Code generated by protoc-gen-mqservice. DO NOT EDIT.
+// source: {{.Source}}
+`
+
+// routingKeysTemplate renders a `RoutingKey` method on the oneof wrapper of each event type.
+var routingKeysTemplate = template.Must(template.New("routingKeys").Parse(header + `
+package mq
+{{range .Events}}
+// RoutingKey is this message's routing key.
+func (msg *SystemEvent_{{.Name}}) RoutingKey() string {
+	return "{{.RoutingKey}}"
+}
+{{end}}`))
+
+// handlersTemplate renders the `InternalMQService` handler of each event type which has a publish RPC.
+var handlersTemplate = template.Must(template.New("handlers").Parse(header + `
+package internalService
+
+import (
+	"context"
+
+	"gitlab.com/project-leaf/mq-service-go/src/proto/mq"
+)
+{{range .Events}}{{with $event := .}}{{with .Handler}}
+// {{.Method}} will publish an ` + "`{{$event.Name}}`" + ` event to the central event bus according to the given request.
+func (service *InternalMQService) {{.Method}}(ctx context.Context, req *mq.{{.Request}}) (*mq.{{.Response}}, error) {
+	response := &mq.{{.Response}}{Error: nil}
+	service.log.Debug("Handling request to publish a {{$event.Name}} event.")
+
+	// Build the event object and send it to the broker.
+	event := &mq.SystemEvent_{{$event.Name}}{
+		{{$event.Name}}: &mq.{{$event.Message}}{
+		{{- range .Fields}}
+			{{.}}: req.Get{{.}}(),
+		{{- end}}
+		},
+	}
+	if err := service.publish(ctx, event, req); err != nil {
+		response.Error = err
+		return response, nil
+	}
+
+	service.log.Debug("{{$event.Name}} event successfully published.")
+	return response, nil
+}
+{{end}}{{end}}{{end}}`))
+
+// topologyTemplate renders the default queue declaration of each event type.
+var topologyTemplate = template.Must(template.New("topology").Parse(header + `
+package broker
+
+// eventQueues are the default queue declarations of the event types, each bound to the ` + "`events`" + `
+// exchange by the routing key of its event type.
+var eventQueues = []queueDeclaration{
+{{- range .Events}}
+	{name: "{{.Queue}}", routingKey: "{{.RoutingKey}}"},
+{{- end}}
+}
+`))
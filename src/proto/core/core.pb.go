@@ -189,7 +189,7 @@ func init() {
 func init() { proto.RegisterFile("core.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 280 bytes of a gzipped FileDescriptorProto
+	// 276 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0x4f, 0x4b, 0x03, 0x31,
 	0x10, 0xc5, 0x49, 0x37, 0x6d, 0xdd, 0xa9, 0x82, 0x04, 0x95, 0x20, 0x3d, 0x2c, 0x7b, 0x71, 0xbd,
 	0xec, 0x41, 0x11, 0xc5, 0x9b, 0x48, 0x6f, 0x0a, 0x92, 0x6f, 0x10, 0xbb, 0x43, 0x5d, 0xda, 0x6e,
@@ -206,6 +206,6 @@ var fileDescriptor0 = []byte{
 	0xb3, 0x17, 0xbd, 0x42, 0x15, 0x4b, 0xf8, 0x18, 0xfd, 0x3e, 0x35, 0x57, 0x41, 0xfb, 0x99, 0x6b,
 	0xbf, 0xe2, 0x2e, 0x57, 0x41, 0x97, 0x0d, 0x1c, 0xc6, 0x35, 0xd7, 0x9b, 0xce, 0xe1, 0x7f, 0xf7,
 	0xe2, 0x0b, 0x93, 0xde, 0x84, 0x9e, 0x5c, 0x45, 0xf0, 0x8f, 0xd2, 0xba, 0x27, 0xed, 0x48, 0xf2,
-	0x82, 0x55, 0x07, 0x2a, 0xd1, 0xeb, 0x24, 0x7c, 0xd2, 0xf5, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff,
-	0x2f, 0x50, 0x7a, 0xae, 0xb2, 0x01, 0x00, 0x00,
+	0x82, 0x55, 0x07, 0x2a, 0xd1, 0xeb, 0x24, 0x7c, 0xd2, 0xf5, 0xcf, 0x00, 0x2f, 0x50, 0x7a, 0xae,
+	0xb2, 0x01, 0x00, 0x00,
 }
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+)
+
+// The catalog of error codes which this service may return.
+//
+// Every `core.Error` built by this service carries one of these codes, along with the `Status`
+// & `Meta` entries which go with it. See `NewError`.
+const (
+	// CodeInternal indicates an unexpected failure within this service.
+	CodeInternal = "INTERNAL"
+	// CodeInvalidArgument indicates that the request was invalid.
+	CodeInvalidArgument = "INVALID_ARGUMENT"
+	// CodeBrokerUnavailable indicates that the broker could not be reached, or closed the connection or channel.
+	CodeBrokerUnavailable = "BROKER_UNAVAILABLE"
+	// CodeBrokerBlocked indicates that the broker has blocked publishing, due to a resource alarm.
+	CodeBrokerBlocked = "BROKER_BLOCKED"
+	// CodeCircuitOpen indicates that calls to the broker are paused after repeated failures.
+	CodeCircuitOpen = "CIRCUIT_OPEN"
+	// CodeResourceExhausted indicates that this service is too busy to accept the event right now.
+	CodeResourceExhausted = "RESOURCE_EXHAUSTED"
+	// CodeAccessRefused indicates that the broker refused this service access to a resource.
+	CodeAccessRefused = "ACCESS_REFUSED"
+	// CodeQueueFull indicates that a queue which the event is routed to is full, and rejects new events.
+	CodeQueueFull = "QUEUE_FULL"
+	// CodeUnroutable indicates that the broker could not route the event to any queue.
+	CodeUnroutable = "UNROUTABLE"
+	// CodeNotFound indicates that an exchange or queue which the operation needs does not exist in the broker.
+	CodeNotFound = "NOT_FOUND"
+	// CodePreconditionFailed indicates that the broker has an exchange or queue declared with other
+	// settings than this service expects.
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	// CodeTimeout indicates that an operation against the broker timed out.
+	CodeTimeout = "TIMEOUT"
+	// CodeDeadlineExceeded indicates that the request deadline passed before the operation could complete.
+	CodeDeadlineExceeded = "DEADLINE_EXCEEDED"
+	// CodeCanceled indicates that the request was cancelled before the operation could complete.
+	CodeCanceled = "CANCELED"
+)
+
+const (
+	// MetaRetryable is the `Meta` key holding a `true` or `false` hint on whether the request may be retried.
+	MetaRetryable = "retryable"
+	// MetaGRPCCode is the `Meta` key holding the name of the gRPC status code which matches the error,
+	// as a convenience copy of the code returned on the call. See `GRPCCode`.
+	MetaGRPCCode = "grpc_code"
+	// MetaReplyCode is the `Meta` key holding the AMQP reply code which caused the error, if any.
+	MetaReplyCode = "reply_code"
+	// MetaQueue is the `Meta` key holding the name of the queue which caused the error, if any. Several
+	// queue names are comma-separated.
+	MetaQueue = "queue"
+)
+
+// errorKind describes the errors of a single error code.
+type errorKind struct {
+	message   string
+	status    uint32
+	grpcCode  codes.Code
+	retryable bool
+}
+
+var catalog = map[string]errorKind{
+	CodeInternal:           {"Internal server error. We're working on it.", 500, codes.Internal, false},
+	CodeInvalidArgument:    {"The request is invalid.", 400, codes.InvalidArgument, false},
+	CodeBrokerUnavailable:  {"The message broker is currently unavailable.", 503, codes.Unavailable, true},
+	CodeBrokerBlocked:      {"The message broker is temporarily refusing events.", 503, codes.Unavailable, true},
+	CodeCircuitOpen:        {"The message broker is currently unavailable. Try again later.", 503, codes.Unavailable, true},
+	CodeResourceExhausted:  {"Too many events are waiting to be published. Try again shortly.", 503, codes.ResourceExhausted, true},
+	CodeAccessRefused:      {"Access to the message broker was refused.", 403, codes.PermissionDenied, false},
+	CodeQueueFull:          {"A queue the event is routed to is full. Try again later.", 503, codes.ResourceExhausted, true},
+	CodeUnroutable:         {"The event could not be routed to any queue.", 404, codes.NotFound, false},
+	CodeNotFound:           {"The message broker is missing an exchange or queue. Its topology needs to be restored.", 503, codes.NotFound, false},
+	CodePreconditionFailed: {"The message broker's topology does not match this service's. It needs to be migrated.", 503, codes.FailedPrecondition, false},
+	CodeTimeout:            {"The operation timed out.", 504, codes.DeadlineExceeded, true},
+	CodeDeadlineExceeded:   {"The request deadline was exceeded before the operation could complete.", 504, codes.DeadlineExceeded, true},
+	CodeCanceled:           {"The request was cancelled before the operation could complete.", 499, codes.Canceled, false},
+}
+
+// NewError will build and return a new `core.Error` instance for the given error code.
+//
+// Unknown error codes are treated as `INTERNAL`.
+func NewError(code string) *Error {
+	kind, ok := catalog[code]
+	if !ok {
+		code, kind = CodeInternal, catalog[CodeInternal]
+	}
+
+	return &Error{
+		Message: kind.message,
+		Status:  kind.status,
+		Code:    code,
+		Meta: map[string]string{
+			MetaRetryable: strconv.FormatBool(kind.retryable),
+			MetaGRPCCode:  kind.grpcCode.String(),
+		},
+	}
+}
+
+// NewInvalidArgument will build and return a new `INVALID_ARGUMENT` error for the given request field.
+func NewInvalidArgument(field, message string) *Error {
+	err := NewError(CodeInvalidArgument)
+	err.Message = message
+	err.Meta["field"] = field
+	return err
+}
+
+// GRPCCode will return the gRPC status code which matches the error.
+//
+// Unknown error codes are treated as `INTERNAL`.
+func (err *Error) GRPCCode() codes.Code {
+	kind, ok := catalog[err.GetCode()]
+	if !ok {
+		return catalog[CodeInternal].grpcCode
+	}
+	return kind.grpcCode
+}
+
+// Retryable reports whether the request which caused this error may be retried.
+func (err *Error) Retryable() bool {
+	return err.GetMeta()[MetaRetryable] == "true"
+}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
@@ -11,17 +14,16 @@ func (err *Error) Error() string {
 	return err.Message
 }
 
-// New500FromError will build and return a `core.Error` instance from the given `error`.
+// NewFromError will build and return a `core.Error` instance from the given `error`.
 //
 // This routine will cast the error against any error types expected to come from
-// this service's dependencies. It will always return an instance with a `500` status.
+// this service's dependencies, and will pick the matching code from the error catalog.
+// Errors which can not be classified are returned as `INTERNAL` errors.
 //
 // NOTE: this function will also log any pertinent info related to the error.
-func New500FromError(err error, log *logrus.Logger) *Error {
-	err500 := NewError500()
-
+func NewFromError(err error, log *logrus.Logger) *Error {
 	switch errType := err.(type) {
-	case amqp.Error:
+	case *amqp.Error:
 		log.WithFields(logrus.Fields{
 			"type":        fmt.Sprintf("%T", errType),
 			"reason":      errType.Reason,
@@ -29,20 +31,58 @@ func New500FromError(err error, log *logrus.Logger) *Error {
 			"recoverable": errType.Recover,
 			"fromServer":  errType.Server,
 		}).Error(errType.Error())
-		return err500
+		coreErr := NewError(codeFromReplyCode(errType.Code))
+		coreErr.Meta[MetaReplyCode] = strconv.Itoa(errType.Code)
+		return coreErr
+
+	case net.Error:
+		log.Errorf("Network error handled: %T: %s", errType, errType.Error())
+		if errType.Timeout() {
+			return NewError(CodeTimeout)
+		}
+		return NewError(CodeBrokerUnavailable)
 
 	default:
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return NewErrorFromContext(err)
+		}
 		log.Errorf("Unknown error handled: %T: %s", errType, errType.Error())
-		return err500
+		return NewError(CodeInternal)
 	}
 }
 
-// NewError500 will construct and return a vanilla 500 error.
-func NewError500() *Error {
-	return &Error{
-		Message: "Internal server error. We're working on it.",
-		Status:  500,
-		Code:    "",
-		Meta:    map[string]string{},
+// NewErrorFromContext will build and return a `core.Error` instance from the given context error.
+//
+// An expired deadline results in a `DEADLINE_EXCEEDED` error, and a cancellation results in a
+// `CANCELED` error. Any other error results in an `INTERNAL` error.
+func NewErrorFromContext(err error) *Error {
+	switch err {
+	case context.DeadlineExceeded:
+		return NewError(CodeDeadlineExceeded)
+	case context.Canceled:
+		return NewError(CodeCanceled)
+	default:
+		return NewError(CodeInternal)
+	}
+}
+
+/////////////////////
+// Private Symbols //
+
+// codeFromReplyCode will map the given AMQP reply code onto the error catalog.
+func codeFromReplyCode(replyCode int) string {
+	switch replyCode {
+	case amqp.AccessRefused:
+		return CodeAccessRefused
+	case amqp.NoRoute:
+		return CodeUnroutable
+	case amqp.NotFound:
+		return CodeNotFound
+	case amqp.PreconditionFailed:
+		return CodePreconditionFailed
+	case amqp.ConnectionForced, amqp.ChannelError, amqp.ResourceError, amqp.ResourceLocked, amqp.InternalError:
+		return CodeBrokerUnavailable
+	default:
+		return CodeInternal
 	}
 }
@@ -0,0 +1,242 @@
+package mq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/snappy"
+	"github.com/streadway/amqp"
+
+	"gitlab.com/project-leaf/mq-service-go/src/proto/core"
+)
+
+const (
+	// ContentTypeProtobuf is the content type of events encoded as binary protobuf.
+	ContentTypeProtobuf = "application/protobuf"
+	// ContentTypeJSON is the content type of events encoded as protobuf-JSON.
+	ContentTypeJSON = "application/json"
+	// ContentTypeCloudEventsJSON is the content type of structured-mode CloudEvents.
+	ContentTypeCloudEventsJSON = "application/cloudevents+json"
+
+	// ContentEncodingGzip is the content encoding of gzip compressed events.
+	ContentEncodingGzip = "gzip"
+	// ContentEncodingSnappy is the content encoding of snappy compressed events.
+	ContentEncodingSnappy = "snappy"
+
+	// MaxDecompressedSize is the largest body which `Decompress` will inflate a compressed body to,
+	// in bytes. It matches the largest message size which RabbitMQ accepts by default.
+	MaxDecompressedSize = 128 << 20
+
+	// CloudEventsSpecVersion is the version of the CloudEvents spec which events conform to.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsHeaderPrefix prefixes the CloudEvents attributes of binary-mode events, per the AMQP protocol binding.
+	CloudEventsHeaderPrefix = "cloudEvents:"
+)
+
+// ErrBodyTooLarge is returned by `Decompress` when a body inflates beyond `MaxDecompressedSize`.
+var ErrBodyTooLarge = errors.New("decompressed body is too large")
+
+// CloudEvent is a structured-mode CloudEvents 1.0 envelope.
+//
+// A `SystemEvent` is mapped onto it as follows: the event's ID, routing key & occurred-at time are
+// the CloudEvents `id`, `type` & `time`, the wrapped event message is the `data`, and the fields
+// of the `core.Context` & the event's remaining metadata are carried as extension attributes.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	RequestID       string          `json:"requestid,omitempty"`
+	CorrelationID   string          `json:"correlationid,omitempty"`
+	CausationID     string          `json:"causationid,omitempty"`
+	Producer        string          `json:"producer,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Compress will compress the given body with the given content encoding.
+func Compress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case ContentEncodingGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case ContentEncodingSnappy:
+		return snappy.Encode(nil, body), nil
+
+	default:
+		return nil, fmt.Errorf("unknown content encoding '%s'", encoding)
+	}
+}
+
+// Decompress will decompress the given body according to the given content encoding.
+//
+// Bodies without a content encoding are returned as they are. Bodies which would inflate beyond
+// `MaxDecompressedSize` are rejected with `ErrBodyTooLarge`.
+func Decompress(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return body, nil
+
+	case ContentEncodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		decompressed, err := ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(decompressed) > MaxDecompressedSize {
+			return nil, ErrBodyTooLarge
+		}
+		return decompressed, nil
+
+	case ContentEncodingSnappy:
+		size, err := snappy.DecodedLen(body)
+		if err != nil {
+			return nil, err
+		}
+		if size > MaxDecompressedSize {
+			return nil, ErrBodyTooLarge
+		}
+		return snappy.Decode(nil, body)
+
+	default:
+		return nil, fmt.Errorf("unknown content encoding '%s'", encoding)
+	}
+}
+
+// DecodeDelivery will decode the `SystemEvent` carried by the given delivery.
+//
+// The body is decompressed according to the delivery's `ContentEncoding`, and then decoded
+// according to its `ContentType`. Every encoding which this service publishes is supported.
+// For CloudEvents, the `SystemEvent` is rebuilt from the envelope's attributes and data.
+//
+// The decoded event is upcast to the current schema version of its event message. See `Upcast`.
+func DecodeDelivery(delivery amqp.Delivery) (*SystemEvent, error) {
+	event, err := decodeDelivery(delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema version is only carried in the envelope for encodings which carry the envelope.
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = schemaVersionHeader(delivery)
+	}
+	if err := Upcast(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// decodeDelivery will decode the `SystemEvent` carried by the given delivery, as it was published.
+func decodeDelivery(delivery amqp.Delivery) (*SystemEvent, error) {
+	body, err := Decompress(delivery.ContentEncoding, delivery.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	event := new(SystemEvent)
+	switch delivery.ContentType {
+	case ContentTypeProtobuf:
+		// Binary-mode CloudEvents carry the bare event message, not a `SystemEvent`.
+		eventType, ok := delivery.Headers[CloudEventsHeaderPrefix+"type"].(string)
+		if !ok {
+			return event, proto.Unmarshal(body, event)
+		}
+		message, err := newEventMessage(eventType)
+		if err != nil {
+			return nil, err
+		}
+		if err := proto.Unmarshal(body, Payload(message)); err != nil {
+			return nil, err
+		}
+		event.Event = message
+
+		attributes := map[string]string{}
+		for name, value := range delivery.Headers {
+			if value, ok := value.(string); ok && strings.HasPrefix(name, CloudEventsHeaderPrefix) {
+				attributes[strings.TrimPrefix(name, CloudEventsHeaderPrefix)] = value
+			}
+		}
+		return event, restoreEnvelope(event, attributes)
+
+	case ContentTypeJSON:
+		return event, jsonpb.Unmarshal(bytes.NewReader(body), event)
+
+	case ContentTypeCloudEventsJSON:
+		var envelope CloudEvent
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, err
+		}
+		message, err := newEventMessage(envelope.Type)
+		if err != nil {
+			return nil, err
+		}
+		if err := jsonpb.Unmarshal(bytes.NewReader(envelope.Data), Payload(message)); err != nil {
+			return nil, err
+		}
+		event.Event = message
+		return event, restoreEnvelope(event, map[string]string{
+			"id":            envelope.ID,
+			"time":          envelope.Time,
+			"requestid":     envelope.RequestID,
+			"correlationid": envelope.CorrelationID,
+			"causationid":   envelope.CausationID,
+			"producer":      envelope.Producer,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown content type '%s'", delivery.ContentType)
+	}
+}
+
+// restoreEnvelope will rebuild the metadata of the given event from its CloudEvents attributes.
+func restoreEnvelope(event *SystemEvent, attributes map[string]string) error {
+	event.Id = attributes["id"]
+	event.CorrelationId = attributes["correlationid"]
+	event.CausationId = attributes["causationid"]
+	event.Producer = attributes["producer"]
+	if requestID := attributes["requestid"]; requestID != "" {
+		event.Context = &core.Context{Requestid: requestID}
+	}
+	if eventTime := attributes["time"]; eventTime != "" {
+		occurredAt, err := time.Parse(time.RFC3339Nano, eventTime)
+		if err != nil {
+			return err
+		}
+		if event.OccurredAt, err = ptypes.TimestampProto(occurredAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEventMessage will build a new `SystemEventMessage` for the given routing key, wrapping an empty event message.
+func newEventMessage(routingKey string) (SystemEventMessage, error) {
+	eventType, ok := LookupEventType(routingKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type '%s'", routingKey)
+	}
+	return eventType.NewMessage(), nil
+}
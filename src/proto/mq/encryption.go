@@ -0,0 +1,183 @@
+package mq
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// HeaderEncryptionAlgorithm is the header holding the algorithm an event's body is encrypted with.
+	HeaderEncryptionAlgorithm = "x-encryption-alg"
+	// HeaderEncryptionKeyID is the header holding the ID of the master key which wrapped an event's data key.
+	HeaderEncryptionKeyID = "x-encryption-key-id"
+	// HeaderEncryptionWrappedKey is the header holding an event's base64 encoded, wrapped data key.
+	HeaderEncryptionWrappedKey = "x-encryption-wrapped-key"
+
+	// EncryptionAlgorithmAES256GCM is the algorithm used to encrypt event bodies & wrap data keys.
+	EncryptionAlgorithmAES256GCM = "AES-256-GCM"
+
+	encryptionKeySize = 32
+)
+
+var (
+	// ErrUnknownEncryptionKey is returned when decrypting an event whose data key was wrapped with a master key which is not known.
+	ErrUnknownEncryptionKey = errors.New("event is encrypted with an unknown key")
+	// ErrDecryptionFailed is returned when an event can not be decrypted, because it is malformed or has been tampered with.
+	ErrDecryptionFailed = errors.New("event could not be decrypted")
+)
+
+// EncryptionKeys maps master key IDs to their AES-256 keys.
+type EncryptionKeys map[string][]byte
+
+// LoadEncryptionKeys will load master keys from the key file at the given path.
+//
+// The file holds one key per line, as `keyID:key` where the key is 32 base64 encoded bytes.
+// Blank lines & lines starting with `#` are ignored. Keys are rotated by adding a new key,
+// encrypting with it, and removing the old key once no events encrypted with it remain in any queue.
+func LoadEncryptionKeys(path string) (EncryptionKeys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := EncryptionKeys{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("line %d of key file is not of the form 'keyID:key'", lineNumber)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("key '%s' of key file must be %d base64 encoded bytes", parts[0], encryptionKeySize)
+		}
+		keys[parts[0]] = key
+	}
+	return keys, scanner.Err()
+}
+
+// Encrypt will encrypt the body of the given publishing, setting the encryption headers.
+//
+// A fresh data key is generated for the publishing. The body is encrypted with the data key, and
+// the data key is wrapped with the given master key. Both use AES-256-GCM, bound to the
+// publishing's `Type` & `MessageId`, which must already be set.
+func Encrypt(keyID string, masterKey []byte, msg *amqp.Publishing) error {
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+
+	aad := encryptionAAD(msg.Type, msg.MessageId)
+	body, err := seal(dataKey, msg.Body, aad)
+	if err != nil {
+		return err
+	}
+	wrappedKey, err := seal(masterKey, dataKey, aad)
+	if err != nil {
+		return err
+	}
+
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Headers[HeaderEncryptionAlgorithm] = EncryptionAlgorithmAES256GCM
+	msg.Headers[HeaderEncryptionKeyID] = keyID
+	msg.Headers[HeaderEncryptionWrappedKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+	msg.Body = body
+	return nil
+}
+
+// DecryptDelivery will return a copy of the given delivery with its body decrypted.
+//
+// Deliveries which are not encrypted are returned as they are. Consumers should call this after
+// verifying an event's signature, and before decoding it.
+func DecryptDelivery(keys EncryptionKeys, delivery amqp.Delivery) (amqp.Delivery, error) {
+	algorithm, ok := delivery.Headers[HeaderEncryptionAlgorithm].(string)
+	if !ok {
+		return delivery, nil
+	}
+	if algorithm != EncryptionAlgorithmAES256GCM {
+		return delivery, fmt.Errorf("unknown encryption algorithm '%s'", algorithm)
+	}
+
+	keyID, _ := delivery.Headers[HeaderEncryptionKeyID].(string)
+	masterKey, ok := keys[keyID]
+	if !ok {
+		return delivery, ErrUnknownEncryptionKey
+	}
+	encodedWrappedKey, _ := delivery.Headers[HeaderEncryptionWrappedKey].(string)
+	wrappedKey, err := base64.StdEncoding.DecodeString(encodedWrappedKey)
+	if err != nil {
+		return delivery, ErrDecryptionFailed
+	}
+
+	aad := encryptionAAD(delivery.Type, delivery.MessageId)
+	dataKey, err := open(masterKey, wrappedKey, aad)
+	if err != nil {
+		return delivery, ErrDecryptionFailed
+	}
+	body, err := open(dataKey, delivery.Body, aad)
+	if err != nil {
+		return delivery, ErrDecryptionFailed
+	}
+
+	delivery.Body = body
+	return delivery, nil
+}
+
+// encryptionAAD will build the additional authenticated data which binds a ciphertext to its event.
+func encryptionAAD(eventType, messageID string) []byte {
+	return []byte(eventType + "\n" + messageID)
+}
+
+// seal will encrypt the given plaintext with AES-GCM, prefixing the ciphertext with its nonce.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open will decrypt the given nonce-prefixed ciphertext with AES-GCM.
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, aad)
+}
+
+// newGCM will build an AES-GCM cipher from the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
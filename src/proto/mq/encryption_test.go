@@ -0,0 +1,86 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncryptDecryptDelivery(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, encryptionKeySize), bytes.Repeat([]byte{2}, encryptionKeySize)
+
+	cases := []struct {
+		name    string
+		keys    EncryptionKeys // The keys which the delivery is decrypted with.
+		corrupt func(headers map[string]interface{}, body []byte) []byte
+		want    error
+	}{
+		{"round trip", EncryptionKeys{"new": newKey}, nil, nil},
+		{"rotated key, old key kept", EncryptionKeys{"old": oldKey, "new": newKey}, nil, nil},
+		{"unknown key ID", EncryptionKeys{"old": oldKey}, nil, ErrUnknownEncryptionKey},
+		{"wrong key", EncryptionKeys{"new": oldKey}, nil, ErrDecryptionFailed},
+		{"corrupted wrapped key", EncryptionKeys{"new": newKey}, func(headers map[string]interface{}, body []byte) []byte {
+			wrappedKey, _ := base64.StdEncoding.DecodeString(headers[HeaderEncryptionWrappedKey].(string))
+			wrappedKey[len(wrappedKey)-1] ^= 1
+			headers[HeaderEncryptionWrappedKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+			return body
+		}, ErrDecryptionFailed},
+		{"wrapped key not base64", EncryptionKeys{"new": newKey}, func(headers map[string]interface{}, body []byte) []byte {
+			headers[HeaderEncryptionWrappedKey] = "not base64!"
+			return body
+		}, ErrDecryptionFailed},
+		{"corrupted body", EncryptionKeys{"new": newKey}, func(headers map[string]interface{}, body []byte) []byte {
+			body[len(body)-1] ^= 1
+			return body
+		}, ErrDecryptionFailed},
+		{"truncated body", EncryptionKeys{"new": newKey}, func(headers map[string]interface{}, body []byte) []byte {
+			return body[:4]
+		}, ErrDecryptionFailed},
+	}
+
+	for _, c := range cases {
+		msg := testPublishing()
+		if err := Encrypt("new", newKey, &msg); err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.name, err.Error())
+		}
+		if bytes.Equal(msg.Body, testPublishing().Body) {
+			t.Fatalf("%s: body was not encrypted", c.name)
+		}
+
+		delivery := testDelivery(msg)
+		if c.corrupt != nil {
+			delivery.Body = c.corrupt(delivery.Headers, delivery.Body)
+		}
+		decrypted, err := DecryptDelivery(c.keys, delivery)
+		if err != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+			continue
+		}
+		if err == nil && !bytes.Equal(decrypted.Body, testPublishing().Body) {
+			t.Errorf("%s: expected body %q, got %q", c.name, testPublishing().Body, decrypted.Body)
+		}
+	}
+}
+
+func TestDecryptDeliveryBoundToEvent(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryptionKeySize)
+	msg := testPublishing()
+	if err := Encrypt("key", key, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ciphertext of one event can not be replayed as another.
+	delivery := testDelivery(msg)
+	delivery.MessageId = "another event"
+	if _, err := DecryptDelivery(EncryptionKeys{"key": key}, delivery); err != ErrDecryptionFailed {
+		t.Errorf("expected %v, got %v", ErrDecryptionFailed, err)
+	}
+}
+
+func TestDecryptDeliveryUnencrypted(t *testing.T) {
+	delivery := testDelivery(testPublishing())
+	decrypted, err := DecryptDelivery(EncryptionKeys{}, delivery)
+	if err != nil || !bytes.Equal(decrypted.Body, delivery.Body) {
+		t.Errorf("expected the delivery as it is, got %q, %v", decrypted.Body, err)
+	}
+}
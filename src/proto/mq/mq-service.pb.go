@@ -15,6 +15,12 @@ It has these top-level messages:
 	PubPhotoScanUploadedResponse
 	PubPhotoScanSampledRequest
 	PubPhotoScanSampledResponse
+	ListEventTypesRequest
+	ListEventTypesResponse
+	EventTypeInfo
+	QueueInfo
+	GetFileDescriptorSetRequest
+	GetFileDescriptorSetResponse
 */
 package mq
 
@@ -22,6 +28,8 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 import core "gitlab.com/project-leaf/mq-service-go/src/proto/core"
+import google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
+import google_protobuf1 "github.com/golang/protobuf/protoc-gen-go/descriptor"
 
 import (
 	context "golang.org/x/net/context"
@@ -46,6 +54,18 @@ type SystemEvent struct {
 	//	*SystemEvent_PhotoScanUploaded
 	//	*SystemEvent_PhotoScanSampled
 	Event isSystemEvent_Event `protobuf_oneof:"event"`
+	// The schema version of the event message. Unversioned events are at version 1.
+	SchemaVersion uint32 `protobuf:"varint,4,opt,name=schemaVersion" json:"schemaVersion,omitempty"`
+	// The unique ID of this event. Consumers may use it to dedupe events.
+	Id string `protobuf:"bytes,5,opt,name=id" json:"id,omitempty"`
+	// When the event occurred.
+	OccurredAt *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=occurredAt" json:"occurredAt,omitempty"`
+	// The authenticated identity of the service which produced this event.
+	Producer string `protobuf:"bytes,7,opt,name=producer" json:"producer,omitempty"`
+	// The ID shared by all requests & events stemming from the same originating request.
+	CorrelationId string `protobuf:"bytes,8,opt,name=correlationId" json:"correlationId,omitempty"`
+	// The ID of the request or event which directly caused this event.
+	CausationId string `protobuf:"bytes,9,opt,name=causationId" json:"causationId,omitempty"`
 }
 
 func (m *SystemEvent) Reset()                    { *m = SystemEvent{} }
@@ -95,6 +115,48 @@ func (m *SystemEvent) GetPhotoScanSampled() *EventPhotoScanSampled {
 	return nil
 }
 
+func (m *SystemEvent) GetSchemaVersion() uint32 {
+	if m != nil {
+		return m.SchemaVersion
+	}
+	return 0
+}
+
+func (m *SystemEvent) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *SystemEvent) GetOccurredAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.OccurredAt
+	}
+	return nil
+}
+
+func (m *SystemEvent) GetProducer() string {
+	if m != nil {
+		return m.Producer
+	}
+	return ""
+}
+
+func (m *SystemEvent) GetCorrelationId() string {
+	if m != nil {
+		return m.CorrelationId
+	}
+	return ""
+}
+
+func (m *SystemEvent) GetCausationId() string {
+	if m != nil {
+		return m.CausationId
+	}
+	return ""
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*SystemEvent) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _SystemEvent_OneofMarshaler, _SystemEvent_OneofUnmarshaler, _SystemEvent_OneofSizer, []interface{}{
@@ -204,6 +266,15 @@ func (m *EventPhotoScanSampled) GetId() string {
 type PubPhotoScanUploadedRequest struct {
 	Context *core.Context `protobuf:"bytes,1,opt,name=context" json:"context,omitempty"`
 	Id      string        `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	// When the event occurred. Defaults to the time it is published.
+	OccurredAt *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=occurredAt" json:"occurredAt,omitempty"`
+	// The ID of the request or event which directly caused the event. Defaults to the request ID.
+	CausationId string `protobuf:"bytes,4,opt,name=causationId" json:"causationId,omitempty"`
+	// The priority of the event, from 0 (default) to 9. Needs queues with a max priority.
+	Priority uint32 `protobuf:"varint,5,opt,name=priority" json:"priority,omitempty"`
+	// How long the event may wait in a queue before it expires, in milliseconds. Defaults to the
+	// queue's TTL, which it may not exceed.
+	ExpirationMs int64 `protobuf:"varint,6,opt,name=expirationMs" json:"expirationMs,omitempty"`
 }
 
 func (m *PubPhotoScanUploadedRequest) Reset()                    { *m = PubPhotoScanUploadedRequest{} }
@@ -225,6 +296,34 @@ func (m *PubPhotoScanUploadedRequest) GetId() string {
 	return ""
 }
 
+func (m *PubPhotoScanUploadedRequest) GetOccurredAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.OccurredAt
+	}
+	return nil
+}
+
+func (m *PubPhotoScanUploadedRequest) GetCausationId() string {
+	if m != nil {
+		return m.CausationId
+	}
+	return ""
+}
+
+func (m *PubPhotoScanUploadedRequest) GetPriority() uint32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *PubPhotoScanUploadedRequest) GetExpirationMs() int64 {
+	if m != nil {
+		return m.ExpirationMs
+	}
+	return 0
+}
+
 type PubPhotoScanUploadedResponse struct {
 	Error *core.Error `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
 }
@@ -244,6 +343,15 @@ func (m *PubPhotoScanUploadedResponse) GetError() *core.Error {
 type PubPhotoScanSampledRequest struct {
 	Context *core.Context `protobuf:"bytes,1,opt,name=context" json:"context,omitempty"`
 	Id      string        `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	// When the event occurred. Defaults to the time it is published.
+	OccurredAt *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=occurredAt" json:"occurredAt,omitempty"`
+	// The ID of the request or event which directly caused the event. Defaults to the request ID.
+	CausationId string `protobuf:"bytes,4,opt,name=causationId" json:"causationId,omitempty"`
+	// The priority of the event, from 0 (default) to 9. Needs queues with a max priority.
+	Priority uint32 `protobuf:"varint,5,opt,name=priority" json:"priority,omitempty"`
+	// How long the event may wait in a queue before it expires, in milliseconds. Defaults to the
+	// queue's TTL, which it may not exceed.
+	ExpirationMs int64 `protobuf:"varint,6,opt,name=expirationMs" json:"expirationMs,omitempty"`
 }
 
 func (m *PubPhotoScanSampledRequest) Reset()                    { *m = PubPhotoScanSampledRequest{} }
@@ -265,6 +373,34 @@ func (m *PubPhotoScanSampledRequest) GetId() string {
 	return ""
 }
 
+func (m *PubPhotoScanSampledRequest) GetOccurredAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.OccurredAt
+	}
+	return nil
+}
+
+func (m *PubPhotoScanSampledRequest) GetCausationId() string {
+	if m != nil {
+		return m.CausationId
+	}
+	return ""
+}
+
+func (m *PubPhotoScanSampledRequest) GetPriority() uint32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *PubPhotoScanSampledRequest) GetExpirationMs() int64 {
+	if m != nil {
+		return m.ExpirationMs
+	}
+	return 0
+}
+
 type PubPhotoScanSampledResponse struct {
 	Error *core.Error `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
 }
@@ -281,6 +417,203 @@ func (m *PubPhotoScanSampledResponse) GetError() *core.Error {
 	return nil
 }
 
+type ListEventTypesRequest struct {
+	Context *core.Context `protobuf:"bytes,1,opt,name=context" json:"context,omitempty"`
+}
+
+func (m *ListEventTypesRequest) Reset()                    { *m = ListEventTypesRequest{} }
+func (m *ListEventTypesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListEventTypesRequest) ProtoMessage()               {}
+func (*ListEventTypesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ListEventTypesRequest) GetContext() *core.Context {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
+type ListEventTypesResponse struct {
+	Error      *core.Error      `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	EventTypes []*EventTypeInfo `protobuf:"bytes,2,rep,name=eventTypes" json:"eventTypes,omitempty"`
+}
+
+func (m *ListEventTypesResponse) Reset()                    { *m = ListEventTypesResponse{} }
+func (m *ListEventTypesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListEventTypesResponse) ProtoMessage()               {}
+func (*ListEventTypesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ListEventTypesResponse) GetError() *core.Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func (m *ListEventTypesResponse) GetEventTypes() []*EventTypeInfo {
+	if m != nil {
+		return m.EventTypes
+	}
+	return nil
+}
+
+// A description of an event type, and of how its events are routed.
+type EventTypeInfo struct {
+	// The full proto name of the event message, e.g. `mq.EventPhotoScanUploaded`.
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The name of the `SystemEvent.event` oneof field carrying the event.
+	Field      string `protobuf:"bytes,2,opt,name=field" json:"field,omitempty"`
+	RoutingKey string `protobuf:"bytes,3,opt,name=routingKey" json:"routingKey,omitempty"`
+	// The queues which the topology may route events of the type to, through any exchange.
+	Queues []*QueueInfo `protobuf:"bytes,4,rep,name=queues" json:"queues,omitempty"`
+	// The content type which events are published with.
+	ContentType string `protobuf:"bytes,5,opt,name=contentType" json:"contentType,omitempty"`
+	// The current schema version of the event message.
+	SchemaVersion uint32 `protobuf:"varint,6,opt,name=schemaVersion" json:"schemaVersion,omitempty"`
+}
+
+func (m *EventTypeInfo) Reset()                    { *m = EventTypeInfo{} }
+func (m *EventTypeInfo) String() string            { return proto.CompactTextString(m) }
+func (*EventTypeInfo) ProtoMessage()               {}
+func (*EventTypeInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *EventTypeInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *EventTypeInfo) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *EventTypeInfo) GetRoutingKey() string {
+	if m != nil {
+		return m.RoutingKey
+	}
+	return ""
+}
+
+func (m *EventTypeInfo) GetQueues() []*QueueInfo {
+	if m != nil {
+		return m.Queues
+	}
+	return nil
+}
+
+func (m *EventTypeInfo) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *EventTypeInfo) GetSchemaVersion() uint32 {
+	if m != nil {
+		return m.SchemaVersion
+	}
+	return 0
+}
+
+// A description of a queue which events are routed to.
+type QueueInfo struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// How long the queue keeps an event, in milliseconds: its message TTL, or the max age of a
+	// stream. Zero if it keeps events until they are consumed.
+	TtlMs int64 `protobuf:"varint,2,opt,name=ttlMs" json:"ttlMs,omitempty"`
+}
+
+func (m *QueueInfo) Reset()                    { *m = QueueInfo{} }
+func (m *QueueInfo) String() string            { return proto.CompactTextString(m) }
+func (*QueueInfo) ProtoMessage()               {}
+func (*QueueInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *QueueInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *QueueInfo) GetTtlMs() int64 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+type GetFileDescriptorSetRequest struct {
+	Context *core.Context `protobuf:"bytes,1,opt,name=context" json:"context,omitempty"`
+}
+
+func (m *GetFileDescriptorSetRequest) Reset()                    { *m = GetFileDescriptorSetRequest{} }
+func (m *GetFileDescriptorSetRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetFileDescriptorSetRequest) ProtoMessage()               {}
+func (*GetFileDescriptorSetRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *GetFileDescriptorSetRequest) GetContext() *core.Context {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
+type GetFileDescriptorSetResponse struct {
+	Error *core.Error `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	// The descriptors of `mq-service.proto` & all of its dependencies, dependencies first.
+	FileDescriptorSet *google_protobuf1.FileDescriptorSet `protobuf:"bytes,2,opt,name=fileDescriptorSet" json:"fileDescriptorSet,omitempty"`
+}
+
+func (m *GetFileDescriptorSetResponse) Reset()                    { *m = GetFileDescriptorSetResponse{} }
+func (m *GetFileDescriptorSetResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetFileDescriptorSetResponse) ProtoMessage()               {}
+func (*GetFileDescriptorSetResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GetFileDescriptorSetResponse) GetError() *core.Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func (m *GetFileDescriptorSetResponse) GetFileDescriptorSet() *google_protobuf1.FileDescriptorSet {
+	if m != nil {
+		return m.FileDescriptorSet
+	}
+	return nil
+}
+
+var E_RoutingKey = &proto.ExtensionDesc{
+	ExtendedType:  (*google_protobuf1.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50001,
+	Name:          "mq.routingKey",
+	Tag:           "bytes,50001,opt,name=routingKey",
+	Filename:      "mq-service.proto",
+}
+
+var E_Queue = &proto.ExtensionDesc{
+	ExtendedType:  (*google_protobuf1.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50002,
+	Name:          "mq.queue",
+	Tag:           "bytes,50002,opt,name=queue",
+	Filename:      "mq-service.proto",
+}
+
+var E_SchemaVersion = &proto.ExtensionDesc{
+	ExtendedType:  (*google_protobuf1.MessageOptions)(nil),
+	ExtensionType: (*uint32)(nil),
+	Field:         50003,
+	Name:          "mq.schemaVersion",
+	Tag:           "varint,50003,opt,name=schemaVersion",
+	Filename:      "mq-service.proto",
+}
+
 func init() {
 	proto.RegisterType((*SystemEvent)(nil), "mq.SystemEvent")
 	proto.RegisterType((*EventPhotoScanUploaded)(nil), "mq.EventPhotoScanUploaded")
@@ -289,6 +622,15 @@ func init() {
 	proto.RegisterType((*PubPhotoScanUploadedResponse)(nil), "mq.PubPhotoScanUploadedResponse")
 	proto.RegisterType((*PubPhotoScanSampledRequest)(nil), "mq.PubPhotoScanSampledRequest")
 	proto.RegisterType((*PubPhotoScanSampledResponse)(nil), "mq.PubPhotoScanSampledResponse")
+	proto.RegisterType((*ListEventTypesRequest)(nil), "mq.ListEventTypesRequest")
+	proto.RegisterType((*ListEventTypesResponse)(nil), "mq.ListEventTypesResponse")
+	proto.RegisterType((*EventTypeInfo)(nil), "mq.EventTypeInfo")
+	proto.RegisterType((*QueueInfo)(nil), "mq.QueueInfo")
+	proto.RegisterType((*GetFileDescriptorSetRequest)(nil), "mq.GetFileDescriptorSetRequest")
+	proto.RegisterType((*GetFileDescriptorSetResponse)(nil), "mq.GetFileDescriptorSetResponse")
+	proto.RegisterExtension(E_RoutingKey)
+	proto.RegisterExtension(E_Queue)
+	proto.RegisterExtension(E_SchemaVersion)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -306,6 +648,10 @@ type InternalMQServiceClient interface {
 	PubPhotoScanUploaded(ctx context.Context, in *PubPhotoScanUploadedRequest, opts ...grpc.CallOption) (*PubPhotoScanUploadedResponse, error)
 	// Publish an event indicating that the specified scan image was successfully sampled.
 	PubPhotoScanSampled(ctx context.Context, in *PubPhotoScanSampledRequest, opts ...grpc.CallOption) (*PubPhotoScanSampledResponse, error)
+	// List every known event type, along with its routing key, bound queues & content type.
+	ListEventTypes(ctx context.Context, in *ListEventTypesRequest, opts ...grpc.CallOption) (*ListEventTypesResponse, error)
+	// Get the proto descriptors of all events, for decoding event payloads dynamically.
+	GetFileDescriptorSet(ctx context.Context, in *GetFileDescriptorSetRequest, opts ...grpc.CallOption) (*GetFileDescriptorSetResponse, error)
 }
 
 type internalMQServiceClient struct {
@@ -334,6 +680,24 @@ func (c *internalMQServiceClient) PubPhotoScanSampled(ctx context.Context, in *P
 	return out, nil
 }
 
+func (c *internalMQServiceClient) ListEventTypes(ctx context.Context, in *ListEventTypesRequest, opts ...grpc.CallOption) (*ListEventTypesResponse, error) {
+	out := new(ListEventTypesResponse)
+	err := grpc.Invoke(ctx, "/mq.InternalMQService/ListEventTypes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalMQServiceClient) GetFileDescriptorSet(ctx context.Context, in *GetFileDescriptorSetRequest, opts ...grpc.CallOption) (*GetFileDescriptorSetResponse, error) {
+	out := new(GetFileDescriptorSetResponse)
+	err := grpc.Invoke(ctx, "/mq.InternalMQService/GetFileDescriptorSet", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for InternalMQService service
 
 type InternalMQServiceServer interface {
@@ -341,6 +705,10 @@ type InternalMQServiceServer interface {
 	PubPhotoScanUploaded(context.Context, *PubPhotoScanUploadedRequest) (*PubPhotoScanUploadedResponse, error)
 	// Publish an event indicating that the specified scan image was successfully sampled.
 	PubPhotoScanSampled(context.Context, *PubPhotoScanSampledRequest) (*PubPhotoScanSampledResponse, error)
+	// List every known event type, along with its routing key, bound queues & content type.
+	ListEventTypes(context.Context, *ListEventTypesRequest) (*ListEventTypesResponse, error)
+	// Get the proto descriptors of all events, for decoding event payloads dynamically.
+	GetFileDescriptorSet(context.Context, *GetFileDescriptorSetRequest) (*GetFileDescriptorSetResponse, error)
 }
 
 func RegisterInternalMQServiceServer(s *grpc.Server, srv InternalMQServiceServer) {
@@ -383,6 +751,42 @@ func _InternalMQService_PubPhotoScanSampled_Handler(srv interface{}, ctx context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _InternalMQService_ListEventTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEventTypesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InternalMQServiceServer).ListEventTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mq.InternalMQService/ListEventTypes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalMQServiceServer).ListEventTypes(ctx, req.(*ListEventTypesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InternalMQService_GetFileDescriptorSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFileDescriptorSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InternalMQServiceServer).GetFileDescriptorSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mq.InternalMQService/GetFileDescriptorSet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalMQServiceServer).GetFileDescriptorSet(ctx, req.(*GetFileDescriptorSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _InternalMQService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "mq.InternalMQService",
 	HandlerType: (*InternalMQServiceServer)(nil),
@@ -395,6 +799,14 @@ var _InternalMQService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PubPhotoScanSampled",
 			Handler:    _InternalMQService_PubPhotoScanSampled_Handler,
 		},
+		{
+			MethodName: "ListEventTypes",
+			Handler:    _InternalMQService_ListEventTypes_Handler,
+		},
+		{
+			MethodName: "GetFileDescriptorSet",
+			Handler:    _InternalMQService_GetFileDescriptorSet_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "mq-service.proto",
@@ -403,26 +815,56 @@ var _InternalMQService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("mq-service.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 330 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x93, 0xcd, 0x4e, 0xc2, 0x40,
-	0x14, 0x85, 0x69, 0x0d, 0x12, 0x2f, 0xd1, 0xc0, 0xf8, 0x13, 0xac, 0x46, 0xb0, 0x1b, 0xd8, 0xd8,
-	0x05, 0xbe, 0x80, 0x3f, 0x21, 0xa2, 0x89, 0x09, 0x4e, 0x03, 0x89, 0xcb, 0xd2, 0xde, 0x44, 0x12,
-	0x3a, 0xd3, 0x4e, 0x07, 0xa2, 0x2f, 0xe8, 0x13, 0xf8, 0x40, 0xa6, 0x33, 0xb5, 0x11, 0x5a, 0x8c,
-	0xc6, 0x65, 0xef, 0x3d, 0xe7, 0xcb, 0x39, 0xb7, 0x2d, 0x34, 0xc2, 0xf8, 0x22, 0x41, 0xb1, 0x9c,
-	0xf9, 0xe8, 0x44, 0x82, 0x4b, 0x4e, 0xcc, 0x30, 0xb6, 0xc0, 0xe7, 0x22, 0x7b, 0xb6, 0x3f, 0x0c,
-	0xa8, 0xbb, 0x6f, 0x89, 0xc4, 0x70, 0xb0, 0x44, 0x26, 0x49, 0x17, 0x6a, 0x3e, 0x67, 0x12, 0x5f,
-	0x65, 0xcb, 0xe8, 0x18, 0xbd, 0x7a, 0x7f, 0xd7, 0x51, 0xea, 0x5b, 0x3d, 0xa4, 0x5f, 0x5b, 0xf2,
-	0x00, 0xcd, 0xe8, 0x85, 0x4b, 0xee, 0xfa, 0x1e, 0x1b, 0x47, 0x73, 0xee, 0x05, 0x18, 0xb4, 0x4c,
-	0x65, 0xb1, 0x9c, 0x30, 0x76, 0x14, 0x6e, 0xb4, 0xae, 0x18, 0x56, 0x68, 0xd1, 0x46, 0xee, 0xa0,
-	0x91, 0x0f, 0x5d, 0x2f, 0x8c, 0xe6, 0x18, 0xb4, 0xb6, 0x14, 0xea, 0xb8, 0x88, 0xca, 0x04, 0xc3,
-	0x0a, 0x2d, 0x98, 0x6e, 0x6a, 0x50, 0xc5, 0x54, 0x6c, 0xf7, 0xe0, 0xa8, 0x3c, 0x00, 0xd9, 0x03,
-	0x73, 0x16, 0xa8, 0x6e, 0x3b, 0xd4, 0x9c, 0x05, 0x76, 0x17, 0x0e, 0x4b, 0xf9, 0x05, 0xe1, 0x04,
-	0x4e, 0x46, 0x8b, 0x69, 0x01, 0x48, 0x31, 0x5e, 0x60, 0xf2, 0x87, 0xc3, 0x69, 0xae, 0x99, 0x73,
-	0xaf, 0xe1, 0xb4, 0x9c, 0x9b, 0x44, 0x9c, 0x25, 0x48, 0xce, 0xa1, 0x8a, 0x42, 0x70, 0x91, 0x61,
-	0xeb, 0x1a, 0x3b, 0x48, 0x47, 0x54, 0x6f, 0xec, 0x31, 0x58, 0xdf, 0x11, 0x59, 0x83, 0x7f, 0x27,
-	0xbb, 0x5a, 0x6d, 0x9c, 0x63, 0x7f, 0x1d, 0xac, 0xff, 0x6e, 0x40, 0xf3, 0x9e, 0x49, 0x14, 0xcc,
-	0x9b, 0x3f, 0x3e, 0xb9, 0xfa, 0x4b, 0x24, 0xcf, 0x70, 0x50, 0xd6, 0x98, 0xb4, 0xd3, 0x97, 0xfd,
-	0xc3, 0x8d, 0xad, 0xce, 0x66, 0x41, 0x96, 0x69, 0x02, 0xfb, 0x25, 0x91, 0xc9, 0xd9, 0xba, 0x71,
-	0xf5, 0x44, 0x56, 0x7b, 0xe3, 0x5e, 0x73, 0xa7, 0xdb, 0xea, 0x6f, 0xb9, 0xfc, 0x0c, 0x00, 0x00,
-	0xff, 0xff, 0x91, 0x2b, 0xf6, 0x7b, 0x51, 0x03, 0x00, 0x00,
+	// 808 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdc, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x0e, 0xf5, 0xe7, 0x68, 0x54, 0x05, 0xd1, 0xd6, 0x09, 0x18, 0xda, 0x8d, 0x58, 0xa2, 0x45,
+	0x75, 0x29, 0x83, 0xba, 0x28, 0x0a, 0xe8, 0x14, 0xb7, 0x75, 0x14, 0xb7, 0x15, 0xea, 0x50, 0xa9,
+	0x01, 0x1f, 0x69, 0x72, 0x24, 0x13, 0x20, 0xb9, 0xd4, 0xee, 0xd2, 0xb0, 0x5e, 0xc0, 0x07, 0x5f,
+	0xfb, 0x16, 0xbd, 0xfb, 0x1d, 0x7a, 0x6d, 0xfb, 0x16, 0xbd, 0xb6, 0x0f, 0x50, 0x70, 0xb9, 0x12,
+	0x24, 0x91, 0xb2, 0xad, 0x6b, 0x6e, 0xdc, 0xd9, 0x6f, 0x3e, 0xee, 0xf7, 0xcd, 0xce, 0x2c, 0x3c,
+	0x8d, 0xa6, 0x5f, 0x72, 0x64, 0x97, 0x81, 0x87, 0x76, 0xc2, 0xa8, 0xa0, 0xa4, 0x12, 0x4d, 0x0d,
+	0xf0, 0x28, 0x53, 0x6b, 0xa3, 0x3b, 0xa1, 0x74, 0x12, 0xe2, 0x2b, 0xb9, 0x3a, 0x4f, 0xc7, 0xaf,
+	0x44, 0x10, 0x21, 0x17, 0x6e, 0x94, 0x28, 0x80, 0xb9, 0x0e, 0xf0, 0x91, 0x7b, 0x2c, 0x48, 0x04,
+	0x65, 0x39, 0xc2, 0xfa, 0xbd, 0x0a, 0xad, 0xd1, 0x8c, 0x0b, 0x8c, 0x8e, 0x2e, 0x31, 0x16, 0xe4,
+	0x0b, 0xd8, 0xf1, 0x68, 0x2c, 0xf0, 0x4a, 0xe8, 0x9a, 0xa9, 0xf5, 0x5a, 0x07, 0x6d, 0x5b, 0xfe,
+	0xf0, 0xfb, 0x3c, 0xe8, 0xcc, 0x77, 0xc9, 0x8f, 0xd0, 0x49, 0x2e, 0xa8, 0xa0, 0x23, 0xcf, 0x8d,
+	0x7f, 0x4d, 0x42, 0xea, 0xfa, 0xe8, 0xeb, 0x15, 0x99, 0x62, 0xd8, 0xd1, 0xd4, 0x96, 0x74, 0x27,
+	0xeb, 0x88, 0xb7, 0x8f, 0x9c, 0x62, 0x1a, 0x19, 0xc0, 0xd3, 0x45, 0x70, 0xe4, 0x46, 0x49, 0x88,
+	0xbe, 0x5e, 0x95, 0x54, 0x2f, 0x8a, 0x54, 0x0a, 0xf0, 0xf6, 0x91, 0x53, 0x48, 0x22, 0x9f, 0x41,
+	0x9b, 0x7b, 0x17, 0x18, 0xb9, 0xa7, 0xc8, 0x78, 0x40, 0x63, 0xbd, 0x66, 0x6a, 0xbd, 0xb6, 0xb3,
+	0x1a, 0x24, 0x4f, 0xa0, 0x12, 0xf8, 0x7a, 0xdd, 0xd4, 0x7a, 0x4d, 0xa7, 0x12, 0xf8, 0xa4, 0x0f,
+	0x40, 0x3d, 0x2f, 0x65, 0x0c, 0xfd, 0x43, 0xa1, 0x37, 0x94, 0x86, 0xdc, 0x3a, 0x7b, 0x6e, 0x9d,
+	0xfd, 0x7e, 0xee, 0xad, 0xb3, 0x84, 0x26, 0x06, 0x3c, 0x4e, 0x18, 0xf5, 0x53, 0x0f, 0x99, 0xbe,
+	0x23, 0x19, 0x17, 0xeb, 0xec, 0x34, 0x1e, 0x65, 0x0c, 0x43, 0x57, 0x04, 0x34, 0x3e, 0xf6, 0xf5,
+	0xc7, 0x12, 0xb0, 0x1a, 0x24, 0x26, 0xb4, 0x3c, 0x37, 0xe5, 0x73, 0x4c, 0x53, 0x62, 0x96, 0x43,
+	0xdf, 0xed, 0x40, 0x1d, 0x33, 0x0b, 0xac, 0x01, 0x3c, 0x2f, 0xb7, 0x55, 0x49, 0xd2, 0xe6, 0x92,
+	0xfa, 0x9f, 0xdc, 0xdc, 0xea, 0x2f, 0x64, 0x12, 0xb7, 0xa5, 0x4b, 0xdc, 0x73, 0x63, 0x3b, 0x55,
+	0x70, 0xeb, 0x08, 0x9e, 0x95, 0x9a, 0x5a, 0xe0, 0xd9, 0xbf, 0xb9, 0xd5, 0xf5, 0x02, 0x0f, 0xcf,
+	0xd1, 0xd6, 0x7f, 0x1a, 0xec, 0x9d, 0xa4, 0xe7, 0x85, 0xe3, 0x38, 0x38, 0x4d, 0x91, 0x6f, 0x71,
+	0x99, 0xf2, 0xdf, 0x56, 0x36, 0x54, 0xa4, 0xba, 0x55, 0x45, 0xd6, 0xfc, 0xac, 0x15, 0xfc, 0xcc,
+	0x6b, 0x16, 0x50, 0x16, 0x88, 0x99, 0xbc, 0x05, 0x6d, 0x67, 0xb1, 0x26, 0x16, 0x7c, 0x84, 0x57,
+	0x49, 0xc0, 0x24, 0x76, 0xc8, 0xe5, 0x6d, 0xa8, 0x3a, 0x2b, 0x31, 0xeb, 0x10, 0xf6, 0xcb, 0x55,
+	0xf3, 0x84, 0xc6, 0x1c, 0xc9, 0xa7, 0x50, 0x47, 0xc6, 0x28, 0x53, 0xa2, 0x5b, 0xb9, 0xe8, 0xa3,
+	0x2c, 0xe4, 0xe4, 0x3b, 0xd6, 0xbf, 0x1a, 0x18, 0xcb, 0x1c, 0xca, 0xff, 0x0f, 0xdc, 0xb8, 0xd7,
+	0xb0, 0x57, 0x2a, 0xfa, 0xe1, 0xbe, 0xbd, 0x86, 0x67, 0x3f, 0x07, 0x5c, 0xc8, 0xcb, 0xfb, 0x7e,
+	0x96, 0x20, 0xdf, 0xd6, 0x31, 0x2b, 0x86, 0xe7, 0xeb, 0x0c, 0x0f, 0xfe, 0x3d, 0xf9, 0x0a, 0x00,
+	0x17, 0x89, 0x7a, 0xc5, 0xac, 0xf6, 0x5a, 0x07, 0x9d, 0xc5, 0x88, 0xca, 0xa2, 0xc7, 0xf1, 0x98,
+	0x3a, 0x4b, 0x20, 0xeb, 0x0f, 0x0d, 0xda, 0x2b, 0xbb, 0x84, 0x40, 0x2d, 0x76, 0x23, 0x54, 0x5d,
+	0x26, 0xbf, 0xc9, 0x2e, 0xd4, 0xc7, 0x01, 0x86, 0xf3, 0x52, 0xe6, 0x0b, 0xf2, 0x12, 0x80, 0xd1,
+	0x54, 0x04, 0xf1, 0xe4, 0x27, 0x9c, 0xc9, 0x6a, 0x36, 0x9d, 0xa5, 0x08, 0xf9, 0x1c, 0x1a, 0xd3,
+	0x14, 0x53, 0xe4, 0x7a, 0x4d, 0x1e, 0xa5, 0x9d, 0x1d, 0xe5, 0x5d, 0x16, 0x91, 0xc7, 0x50, 0x9b,
+	0xb2, 0xb0, 0x99, 0xfa, 0xfc, 0x0c, 0x6a, 0xf0, 0x2d, 0x87, 0x8a, 0x73, 0xb3, 0x51, 0x32, 0x37,
+	0xad, 0x6f, 0xa0, 0xb9, 0x20, 0xdf, 0xa4, 0x42, 0x88, 0x70, 0xc8, 0xa5, 0x8a, 0xaa, 0x93, 0x2f,
+	0xac, 0x37, 0xb0, 0x37, 0x40, 0xf1, 0x26, 0x08, 0xf1, 0x87, 0xc5, 0xeb, 0x33, 0x42, 0xb1, 0x75,
+	0xe5, 0x7e, 0xd3, 0x60, 0xbf, 0x9c, 0xe8, 0xe1, 0x05, 0x3c, 0x81, 0xce, 0x78, 0x3d, 0x5f, 0xbd,
+	0x5a, 0x56, 0xa1, 0x4d, 0x8a, 0x7f, 0x2a, 0x26, 0x1f, 0xfc, 0x53, 0x81, 0xce, 0x71, 0x2c, 0x90,
+	0xc5, 0x6e, 0x38, 0x7c, 0x37, 0xca, 0xdf, 0x6b, 0x72, 0x06, 0xbb, 0x65, 0x23, 0x82, 0x74, 0xb3,
+	0x0a, 0xdd, 0x31, 0x32, 0x0d, 0x73, 0x33, 0x40, 0xa9, 0x3c, 0x85, 0x8f, 0x4b, 0x9a, 0x88, 0xbc,
+	0x5c, 0x4f, 0x5c, 0x1d, 0x29, 0x46, 0x77, 0xe3, 0xbe, 0xe2, 0x1d, 0xc0, 0x93, 0xd5, 0xc6, 0x20,
+	0xf2, 0xf1, 0x2d, 0x6d, 0x37, 0xc3, 0x28, 0xdb, 0x52, 0x44, 0x67, 0xb0, 0x5b, 0x56, 0xa6, 0x5c,
+	0xfb, 0x1d, 0x37, 0xc1, 0x30, 0x37, 0x03, 0x72, 0xea, 0xfe, 0xe1, 0x72, 0x43, 0x90, 0x6e, 0xa1,
+	0x62, 0x43, 0xe4, 0xdc, 0x9d, 0xe0, 0x2f, 0x49, 0x36, 0x70, 0xb8, 0xfe, 0xe7, 0x75, 0xa1, 0x67,
+	0xfa, 0xdf, 0x42, 0x5d, 0xb6, 0xc5, 0xfd, 0xd9, 0x7f, 0xa9, 0xec, 0x1c, 0xdf, 0x1f, 0xac, 0xf5,
+	0xc8, 0xfd, 0x04, 0x7f, 0x5f, 0x57, 0x4b, 0xda, 0xe8, 0xbc, 0x21, 0xf1, 0x5f, 0xff, 0x3f, 0x00,
+	0x31, 0xf0, 0x15, 0x65, 0xe0, 0x09, 0x00, 0x00,
 }
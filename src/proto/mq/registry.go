@@ -0,0 +1,197 @@
+package mq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// protoFile is the name of the proto file declaring `SystemEvent` & its event messages.
+const protoFile = "mq-service.proto"
+
+// EventType describes a type of event carried by the `SystemEvent.event` oneof.
+//
+// Event types are registered from the proto descriptors, so adding an event only means adding
+// its message to the oneof & running protoc-gen-mqservice. Each event message may declare its
+// routing key with the `(mq.routingKey)` message option, which defaults to `events.` followed by
+// the lowercased name of its oneof field, and which is generated into the `RoutingKey` method of
+// its oneof wrapper. The queue bound to the routing key is declared with `(mq.queue)`, defaulting
+// to the routing key, and is generated into the default queue declarations of the broker. The
+// current schema version of the event message is declared with `(mq.schemaVersion)`, defaulting to
+// `1`. Bump the schema version along with any breaking change to the event message,
+// and register an `Upcaster` from the previous version.
+type EventType struct {
+	Name          string // The full proto name of the event message, e.g. `mq.EventPhotoScanUploaded`.
+	Field         string // The name of the `SystemEvent.event` oneof field carrying the event.
+	RoutingKey    string // As returned by the `RoutingKey` method of its oneof wrapper.
+	SchemaVersion uint32
+
+	wrapper reflect.Type // The oneof wrapper type, e.g. `*SystemEvent_PhotoScanUploaded`.
+}
+
+var (
+	registryOnce           sync.Once
+	eventTypes             []*EventType // In the order of the oneof fields.
+	eventTypesByRoutingKey map[string]*EventType
+	eventTypesByWrapper    map[reflect.Type]*EventType
+)
+
+// EventTypes will return every registered event type, in the order of the `SystemEvent.event` oneof fields.
+func EventTypes() []*EventType {
+	registryOnce.Do(loadEventTypes)
+	return eventTypes
+}
+
+// EventTypeOf will return the event type of the given event message.
+//
+// Every `SystemEventMessage` has an event type, so this routine only returns nil for a nil message.
+func EventTypeOf(message SystemEventMessage) *EventType {
+	registryOnce.Do(loadEventTypes)
+	return eventTypesByWrapper[reflect.TypeOf(message)]
+}
+
+// LookupEventType will return the event type with the given routing key, if any.
+func LookupEventType(routingKey string) (*EventType, bool) {
+	registryOnce.Do(loadEventTypes)
+	eventType, ok := eventTypesByRoutingKey[routingKey]
+	return eventType, ok
+}
+
+// DefaultRoutingKey will return the routing key of an event type which declares none, given the
+// name of its `SystemEvent.event` oneof field.
+func DefaultRoutingKey(field string) string {
+	return "events." + strings.ToLower(field)
+}
+
+// NewMessage will build a new `SystemEventMessage` of this event type, wrapping an empty event message.
+func (eventType *EventType) NewMessage() SystemEventMessage {
+	message := reflect.New(eventType.wrapper.Elem())
+	payload := message.Elem().Field(0)
+	payload.Set(reflect.New(payload.Type().Elem()))
+	return message.Interface().(SystemEventMessage)
+}
+
+// Payload will return the event message wrapped by the given `SystemEventMessage`.
+func Payload(message SystemEventMessage) proto.Message {
+	// Each wrapper has a single field, holding the wrapped event message.
+	payload, _ := reflect.ValueOf(message).Elem().Field(0).Interface().(proto.Message)
+	return payload
+}
+
+// FileDescriptorSet will return the descriptors of the proto file declaring the event types & of
+// all of its dependencies, dependencies first. Clients may use them to decode events dynamically.
+func FileDescriptorSet() (*descriptor.FileDescriptorSet, error) {
+	set := new(descriptor.FileDescriptorSet)
+	added := map[string]bool{}
+	if err := addFileDescriptor(set, added, protoFile); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+///////////////////////
+// Private Interface //
+
+// loadEventTypes will build the event type registry from the proto descriptors.
+//
+// The descriptors are compiled into this package, so any failure here is a programming error.
+func loadEventTypes() {
+	file, err := fileDescriptor(protoFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load the '%s' descriptor: %s", protoFile, err.Error()))
+	}
+
+	messages := map[string]*descriptor.DescriptorProto{}
+	for _, message := range file.GetMessageType() {
+		messages[fmt.Sprintf(".%s.%s", file.GetPackage(), message.GetName())] = message
+	}
+
+	// Map the oneof fields to their wrapper types.
+	wrappers := map[string]reflect.Type{}
+	_, _, _, oneofWrappers := (*SystemEvent)(nil).XXX_OneofFuncs()
+	for _, wrapper := range oneofWrappers {
+		wrapperType := reflect.TypeOf(wrapper)
+		var props proto.Properties
+		props.Parse(wrapperType.Elem().Field(0).Tag.Get("protobuf"))
+		wrappers[props.OrigName] = wrapperType
+	}
+
+	eventTypesByRoutingKey = map[string]*EventType{}
+	eventTypesByWrapper = map[reflect.Type]*EventType{}
+	for _, field := range messages[".mq.SystemEvent"].GetField() {
+		if field.OneofIndex == nil {
+			continue
+		}
+		wrapper := wrappers[field.GetName()]
+		eventType := &EventType{
+			Name:          strings.TrimPrefix(field.GetTypeName(), "."),
+			Field:         field.GetName(),
+			RoutingKey:    reflect.Zero(wrapper).Interface().(SystemEventMessage).RoutingKey(),
+			SchemaVersion: 1,
+			wrapper:       wrapper,
+		}
+		if options := messages[field.GetTypeName()].GetOptions(); options != nil {
+			if value, err := proto.GetExtension(options, E_SchemaVersion); err == nil {
+				eventType.SchemaVersion = *value.(*uint32)
+			}
+		}
+
+		if _, ok := eventTypesByRoutingKey[eventType.RoutingKey]; ok {
+			panic(fmt.Sprintf("Event routing key '%s' is declared by more than one event type.", eventType.RoutingKey))
+		}
+		eventTypes = append(eventTypes, eventType)
+		eventTypesByRoutingKey[eventType.RoutingKey] = eventType
+		eventTypesByWrapper[eventType.wrapper] = eventType
+	}
+}
+
+// addFileDescriptor will add the descriptor of the given proto file to the given set, after those
+// of its dependencies. Files which have already been added are skipped.
+func addFileDescriptor(set *descriptor.FileDescriptorSet, added map[string]bool, filename string) error {
+	if added[filename] {
+		return nil
+	}
+	added[filename] = true
+
+	file, err := fileDescriptor(filename)
+	if err != nil {
+		return err
+	}
+	for _, dependency := range file.GetDependency() {
+		if err := addFileDescriptor(set, added, dependency); err != nil {
+			return err
+		}
+	}
+	set.File = append(set.File, file)
+	return nil
+}
+
+// fileDescriptor will decode the registered descriptor of the given proto file.
+func fileDescriptor(filename string) (*descriptor.FileDescriptorProto, error) {
+	compressed := proto.FileDescriptor(filename)
+	if compressed == nil {
+		return nil, fmt.Errorf("proto file '%s' is not registered", filename)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	file := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(raw, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,92 @@
+package mq
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// HeaderSchemaVersion is the header holding the schema version of an event message, as an integer.
+//
+// Unlike the other headers of this service, it is not prefixed with `x-`, which headers exchanges
+// ignore, so that queues may be bound by schema version. See `config.HeaderBindings`.
+const HeaderSchemaVersion = "schema-version"
+
+// Upcaster converts an event from one schema version of its event message to the next.
+//
+// The event's message has already been decoded into the current message type, so upcasters
+// typically fill in new fields from the ones they replace.
+type Upcaster func(event *SystemEvent) error
+
+var (
+	upcastersLock sync.RWMutex
+	upcasters     = map[string]map[uint32]Upcaster{} // Keyed by routing key, then by the version upcast from.
+)
+
+// RegisterUpcaster will register an upcaster for the event type with the given routing key, which
+// converts its events from the given schema version to the next. See `EventType.SchemaVersion`.
+//
+// Upcasters are meant to be registered from `init` functions.
+func RegisterUpcaster(routingKey string, fromVersion uint32, upcaster Upcaster) {
+	upcastersLock.Lock()
+	defer upcastersLock.Unlock()
+
+	if upcasters[routingKey] == nil {
+		upcasters[routingKey] = map[uint32]Upcaster{}
+	}
+	upcasters[routingKey][fromVersion] = upcaster
+}
+
+// Upcast will convert the given event to the current schema version of its event message, one
+// version at a time, using the registered upcasters.
+//
+// Events without a schema version are taken to be at version 1. Events from a newer schema version
+// than the current one are rejected, as are events missing an upcaster for any version on the way.
+func Upcast(event *SystemEvent) error {
+	message, ok := event.GetEvent().(SystemEventMessage)
+	if !ok {
+		return fmt.Errorf("event has no event message: %T", event.GetEvent())
+	}
+
+	eventType := EventTypeOf(message)
+	version, current := event.GetSchemaVersion(), eventType.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	if version > current {
+		return fmt.Errorf("event '%s' is at schema version %d, newer than the current version %d", eventType.RoutingKey, version, current)
+	}
+
+	upcastersLock.RLock()
+	defer upcastersLock.RUnlock()
+
+	for ; version < current; version++ {
+		upcaster, ok := upcasters[eventType.RoutingKey][version]
+		if !ok {
+			return fmt.Errorf("no upcaster is registered for event '%s' at schema version %d", eventType.RoutingKey, version)
+		}
+		if err := upcaster(event); err != nil {
+			return err
+		}
+	}
+
+	event.SchemaVersion = current
+	return nil
+}
+
+// schemaVersionHeader will read the schema version from the headers of the given delivery, if any.
+func schemaVersionHeader(delivery amqp.Delivery) uint32 {
+	switch version := delivery.Headers[HeaderSchemaVersion].(type) {
+	case int32:
+		return uint32(version)
+	case int64:
+		return uint32(version)
+	case string:
+		parsed, _ := strconv.ParseUint(version, 10, 32)
+		return uint32(parsed)
+	default:
+		return 0
+	}
+}
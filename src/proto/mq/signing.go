@@ -0,0 +1,185 @@
+package mq
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// HeaderSignature is the header holding an event's base64 encoded signature.
+	HeaderSignature = "x-signature"
+	// HeaderSignatureKeyID is the header holding the ID of the key which signed an event.
+	HeaderSignatureKeyID = "x-signature-key-id"
+	// HeaderSignatureAlgorithm is the header holding the algorithm used to sign an event.
+	HeaderSignatureAlgorithm = "x-signature-alg"
+	// HeaderSignedHeaders is the header listing the headers covered by an event's signature, comma-separated.
+	HeaderSignedHeaders = "x-signature-headers"
+
+	// SignatureAlgorithmHMACSHA256 is the algorithm used to sign events.
+	SignatureAlgorithmHMACSHA256 = "hmac-sha256"
+)
+
+var (
+	// ErrUnsigned is returned when verifying an event which has not been signed.
+	ErrUnsigned = errors.New("event is not signed")
+	// ErrUnknownSigningKey is returned when verifying an event signed with a key which is not known.
+	ErrUnknownSigningKey = errors.New("event is signed with an unknown key")
+	// ErrInvalidSignature is returned when verifying an event whose signature does not match its content.
+	ErrInvalidSignature = errors.New("event signature is invalid")
+)
+
+// SigningKeys maps signing key IDs to their HMAC secrets.
+//
+// Keys are rotated by adding a new key, signing with it, and removing the old key once no
+// events signed with it remain in any queue.
+type SigningKeys map[string][]byte
+
+// ParseSigningKeys will parse the given base64 encoded signing keys, keyed by their IDs.
+func ParseSigningKeys(encoded map[string]string) (SigningKeys, error) {
+	keys := SigningKeys{}
+	for keyID, encodedKey := range encoded {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key '%s' is not valid base64: %s", keyID, err.Error())
+		}
+		if len(key) < sha256.Size {
+			return nil, fmt.Errorf("signing key '%s' must be at least %d bytes", keyID, sha256.Size)
+		}
+		keys[keyID] = key
+	}
+	return keys, nil
+}
+
+// Sign will sign the given publishing with the given key, setting the signature headers.
+//
+// The signature covers the body along with the `Type`, `MessageId`, `Timestamp`, `AppId`,
+// `ContentType`, `ContentEncoding`, `CorrelationId` & `Priority` properties, and every header set
+// on the publishing so far. The `Expiration` property is not covered, as the broker removes it
+// when it dead-letters an event or moves it to another queue. The names of the signed headers are listed in the
+// `x-signature-headers` header, so that headers added by the broker on the way to a consumer, such
+// as `x-death`, do not invalidate the signature. Signing must therefore be the last change made to
+// the publishing before it is sent.
+func Sign(keyID string, key []byte, msg *amqp.Publishing) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	var names []string
+	for name := range msg.Headers {
+		if !signatureHeaders[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	properties := signedProperties(msg.Type, msg.MessageId, msg.Timestamp, msg.AppId, msg.ContentType, msg.ContentEncoding, msg.CorrelationId, msg.Priority)
+	signature := sign(key, properties, msg.Headers, names, msg.Body)
+	msg.Headers[HeaderSignature] = base64.StdEncoding.EncodeToString(signature)
+	msg.Headers[HeaderSignatureKeyID] = keyID
+	msg.Headers[HeaderSignatureAlgorithm] = SignatureAlgorithmHMACSHA256
+	msg.Headers[HeaderSignedHeaders] = strings.Join(names, ",")
+}
+
+// VerifyDelivery will verify the signature of the given delivery against the given keys.
+//
+// Consumers should call this before decoding an event, and reject the delivery if an error is
+// returned: `ErrUnsigned`, `ErrUnknownSigningKey` or `ErrInvalidSignature`.
+func VerifyDelivery(keys SigningKeys, delivery amqp.Delivery) error {
+	encodedSignature, hasSignature := delivery.Headers[HeaderSignature].(string)
+	keyID, hasKeyID := delivery.Headers[HeaderSignatureKeyID].(string)
+	if !hasSignature || !hasKeyID {
+		return ErrUnsigned
+	}
+	if algorithm, _ := delivery.Headers[HeaderSignatureAlgorithm].(string); algorithm != SignatureAlgorithmHMACSHA256 {
+		return ErrInvalidSignature
+	}
+	signedHeaders, ok := delivery.Headers[HeaderSignedHeaders].(string)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	var names []string
+	if signedHeaders != "" {
+		names = strings.Split(signedHeaders, ",")
+	}
+	for _, name := range names {
+		if _, ok := delivery.Headers[name]; !ok || signatureHeaders[name] {
+			return ErrInvalidSignature
+		}
+	}
+
+	key, ok := keys[keyID]
+	if !ok {
+		return ErrUnknownSigningKey
+	}
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	properties := signedProperties(delivery.Type, delivery.MessageId, delivery.Timestamp, delivery.AppId, delivery.ContentType, delivery.ContentEncoding, delivery.CorrelationId, delivery.Priority)
+	expected := sign(key, properties, delivery.Headers, names, delivery.Body)
+	if !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signatureHeaders are the headers set by `Sign`, which the signature can not cover.
+var signatureHeaders = map[string]bool{
+	HeaderSignature:          true,
+	HeaderSignatureKeyID:     true,
+	HeaderSignatureAlgorithm: true,
+	HeaderSignedHeaders:      true,
+}
+
+// signedProperties will return the given event properties, in the order they are signed in.
+//
+// The timestamp is signed with second precision, as that is all AMQP carries over the wire.
+func signedProperties(eventType, messageID string, timestamp time.Time, appID, contentType, contentEncoding, correlationID string, priority uint8) []string {
+	return []string{
+		eventType,
+		messageID,
+		strconv.FormatInt(timestamp.Unix(), 10),
+		appID,
+		contentType,
+		contentEncoding,
+		correlationID,
+		strconv.Itoa(int(priority)),
+	}
+}
+
+// sign will compute the HMAC of the given event properties, the given headers & the body.
+//
+// Each property, header name & header value is length-prefixed, so that no two distinct events
+// sign the same material.
+func sign(key []byte, properties []string, headers amqp.Table, names []string, body []byte) []byte {
+	var buf bytes.Buffer
+	write := func(value string) {
+		buf.WriteString(strconv.Itoa(len(value)))
+		buf.WriteByte(':')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+	for _, property := range properties {
+		write(property)
+	}
+	write(strconv.Itoa(len(names)))
+	for _, name := range names {
+		write(name)
+		write(fmt.Sprint(headers[name]))
+	}
+	buf.Write(body)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf.Bytes())
+	return mac.Sum(nil)
+}
@@ -0,0 +1,87 @@
+package mq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestSignVerifyDelivery(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	cases := []struct {
+		name   string
+		keyID  string               // The key which the event is signed with.
+		keys   SigningKeys          // The keys which the delivery is verified against.
+		tamper func(*amqp.Delivery) // Changes made to the delivery on its way to the consumer.
+		want   error
+	}{
+		{"round trip", "new", SigningKeys{"new": newKey}, func(*amqp.Delivery) {}, nil},
+		{"tampered body", "new", SigningKeys{"new": newKey}, func(delivery *amqp.Delivery) { delivery.Body = []byte("tampered") }, ErrInvalidSignature},
+		{"tampered property", "new", SigningKeys{"new": newKey}, func(delivery *amqp.Delivery) { delivery.Type = "events.other" }, ErrInvalidSignature},
+		{"tampered header", "new", SigningKeys{"new": newKey}, func(delivery *amqp.Delivery) { delivery.Headers["region"] = "us" }, ErrInvalidSignature},
+		{"removed header", "new", SigningKeys{"new": newKey}, func(delivery *amqp.Delivery) { delete(delivery.Headers, "region") }, ErrInvalidSignature},
+		{"dead-lettered", "new", SigningKeys{"new": newKey}, func(delivery *amqp.Delivery) {
+			delivery.Expiration = ""
+			delivery.Headers["x-death"] = []interface{}{amqp.Table{"reason": "expired"}}
+		}, nil},
+		{"unknown key ID", "other", SigningKeys{"new": newKey}, func(*amqp.Delivery) {}, ErrUnknownSigningKey},
+		{"rotated key, old key kept", "old", SigningKeys{"old": oldKey, "new": newKey}, func(*amqp.Delivery) {}, nil},
+		{"rotated key, old key removed", "old", SigningKeys{"new": newKey}, func(*amqp.Delivery) {}, ErrUnknownSigningKey},
+		{"rotated key, wrong secret", "old", SigningKeys{"old": newKey}, func(*amqp.Delivery) {}, ErrInvalidSignature},
+		{"unsigned", "", SigningKeys{"new": newKey}, func(*amqp.Delivery) {}, ErrUnsigned},
+	}
+
+	for _, c := range cases {
+		msg := testPublishing()
+		if c.keyID != "" {
+			Sign(c.keyID, map[string][]byte{"old": oldKey, "new": newKey, "other": newKey}[c.keyID], &msg)
+		}
+		delivery := testDelivery(msg)
+		c.tamper(&delivery)
+		if err := VerifyDelivery(c.keys, delivery); err != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+// testPublishing will build a publishing as this service publishes events.
+func testPublishing() amqp.Publishing {
+	return amqp.Publishing{
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     "c0ffee00-0000-4000-8000-000000000000",
+		CorrelationId: "request",
+		Timestamp:     time.Unix(1500000000, 0),
+		Type:          "events.photoscan.uploaded",
+		AppId:         "mq-service",
+		ContentType:   ContentTypeProtobuf,
+		Priority:      3,
+		Expiration:    "60000",
+		Headers:       amqp.Table{HeaderSchemaVersion: int64(1), "region": "eu"},
+		Body:          []byte("event"),
+	}
+}
+
+// testDelivery will build the delivery of the given publishing, as a consumer receives it.
+func testDelivery(msg amqp.Publishing) amqp.Delivery {
+	headers := amqp.Table{}
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	return amqp.Delivery{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		AppId:           msg.AppId,
+		Body:            append([]byte{}, msg.Body...),
+	}
+}